@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"strings"
+	"time"
+)
+
+const (
+	reactionsFlag     = "--reactions"
+	reactionBatchSize = 100
+)
+
+/*
+reaction tally structure {{{
+*/
+type CustomEmojiCount struct {
+	Url   string `json:"url"`
+	Count int    `json:"count"`
+}
+type Reactions struct {
+	Likes       int                         `json:"likes"`
+	Dislikes    int                         `json:"dislikes"`
+	Reposts     int                         `json:"reposts"`
+	Emoji       map[string]int              `json:"emoji,omitempty"`
+	CustomEmoji map[string]CustomEmojiCount `json:"customEmoji,omitempty"`
+}
+
+// }}}
+
+/* newReactions {{{
+ */
+func newReactions() *Reactions {
+	return &Reactions{
+		Emoji:       map[string]int{},
+		CustomEmoji: map[string]CustomEmojiCount{},
+	}
+}
+
+// }}}
+
+/* Reactions.add {{{
+
+WHAT'S THIS?
+Counts one kind 6 or kind 7 event.
+NIP-25: "+" or empty content is a like, "-" is a dislike, and a
+":shortcode:" with an emoji tag is a NIP-30 custom emoji.
+*/
+func (r *Reactions) add(ev *nostr.Event) {
+	switch ev.Kind {
+	case nostr.KindRepost:
+		r.Reposts++
+	case nostr.KindReaction:
+		switch ev.Content {
+		case "+", "":
+			r.Likes++
+		case "-":
+			r.Dislikes++
+		default:
+			if isShortCode(ev.Content) {
+				sc := strings.Trim(ev.Content, ":")
+				if url := getEmojiUrl(ev.Tags, sc); url != "" {
+					ce := r.CustomEmoji[sc]
+					ce.Url = url
+					ce.Count++
+					r.CustomEmoji[sc] = ce
+					return
+				}
+			}
+			r.Emoji[ev.Content]++
+		}
+	}
+}
+
+// }}}
+
+/* getEmojiUrl {{{
+ */
+func getEmojiUrl(tgs nostr.Tags, shortCode string) string {
+	for _, tg := range tgs {
+		if len(tg) < 3 || tg[indexTagName] != "emoji" {
+			continue
+		}
+		if tg[1] == shortCode {
+			return tg[2]
+		}
+	}
+	return ""
+}
+
+// }}}
+
+/* getReactionTarget {{{
+
+WHAT'S THIS?
+Returns the event id that the reaction or repost points to.
+NIP-25 says the last "e" tag is the target.
+*/
+func getReactionTarget(ev *nostr.Event) string {
+	target := ""
+	for _, tg := range ev.Tags {
+		if len(tg) < 2 || tg[indexTagName] != "e" {
+			continue
+		}
+		target = tg[1]
+	}
+	return target
+}
+
+// }}}
+
+/* tallyReactions {{{
+ */
+func tallyReactions(ids []string, evs []*nostr.Event) map[string]*Reactions {
+	ret := make(map[string]*Reactions)
+	for _, id := range ids {
+		ret[id] = newReactions()
+	}
+	for _, ev := range evs {
+		if r, ok := ret[getReactionTarget(ev)]; ok {
+			r.add(ev)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+/* mkReactionFilters {{{
+
+WHAT'S THIS?
+Builds the filters for kind 6 and kind 7 events referencing ids.
+The ids are split into batches, and all batches are sent in one REQ.
+*/
+func mkReactionFilters(ids []string) nostr.Filters {
+	filters := nostr.Filters{}
+	for i := 0; i < len(ids); i += reactionBatchSize {
+		end := i + reactionBatchSize
+		if len(ids) < end {
+			end = len(ids)
+		}
+		filters = append(filters, nostr.Filter{
+			Kinds: []int{nostr.KindRepost, nostr.KindReaction},
+			Tags:  nostr.TagMap{"e": ids[i:end]},
+		})
+	}
+	return filters
+}
+
+// }}}
+
+/* fetchReactions {{{
+ */
+func fetchReactions(ctx context.Context, cc confClass, pool *nostr.SimplePool, rs []string, ids []string, wt time.Duration, mf MuteFilter) map[string]*Reactions {
+	if len(ids) < 1 {
+		return tallyReactions(ids, nil)
+	}
+	ctx, cancel := context.WithTimeout(ctx, wt)
+	defer cancel()
+
+	evs := []*nostr.Event{}
+	for ev := range cc.subManyEose(ctx, pool, rs, mkReactionFilters(ids)) {
+		if mf.isMuted(ev.Event) {
+			continue
+		}
+		evs = append(evs, ev.Event)
+	}
+	return tallyReactions(ids, evs)
+}
+
+// }}}
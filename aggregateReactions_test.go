@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"testing"
+)
+
+func TestTallyReactions(t *testing.T) {
+	const (
+		idA = "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"
+		idB = "4cf6b2efbf6ff7a7e3f3a5db1d7c7b3b7a05ef1bca9d1bbd0f8fa5c0da3fa4ec"
+		url = "https://example.com/smile.webp"
+	)
+	evs := []*nostr.Event{
+		{Kind: 7, Content: "+", Tags: nostr.Tags{{"e", idA}}},
+		{Kind: 7, Content: "", Tags: nostr.Tags{{"e", idA}}},
+		{Kind: 7, Content: "-", Tags: nostr.Tags{{"e", idA}}},
+		{Kind: 7, Content: "🤙", Tags: nostr.Tags{{"e", idA}}},
+		{Kind: 7, Content: ":smile:", Tags: nostr.Tags{{"e", idA}, {"emoji", "smile", url}}},
+		{Kind: 7, Content: ":smile:", Tags: nostr.Tags{{"e", idA}, {"emoji", "smile", url}}},
+		{Kind: 7, Content: ":nourl:", Tags: nostr.Tags{{"e", idA}}},
+		{Kind: 7, Content: "+", Tags: nostr.Tags{{"e", idA}, {"e", idB}}},
+		{Kind: 6, Content: "", Tags: nostr.Tags{{"e", idB}}},
+		{Kind: 7, Content: "+", Tags: nostr.Tags{{"e", "unknown"}}},
+	}
+	tally := tallyReactions([]string{idA, idB}, evs)
+
+	a := tally[idA]
+	if a.Likes != 2 || a.Dislikes != 1 || a.Reposts != 0 {
+		t.Fatalf("got likes: %v, dislikes: %v, reposts: %v", a.Likes, a.Dislikes, a.Reposts)
+	}
+	if a.Emoji["🤙"] != 1 || a.Emoji[":nourl:"] != 1 {
+		t.Fatalf("got emoji: %v", a.Emoji)
+	}
+	if ce := a.CustomEmoji["smile"]; ce.Count != 2 || ce.Url != url {
+		t.Fatalf("got custom emoji: %v", a.CustomEmoji)
+	}
+
+	b := tally[idB]
+	if b.Likes != 1 || b.Reposts != 1 {
+		t.Fatalf("got likes: %v, reposts: %v", b.Likes, b.Reposts)
+	}
+}
+
+func TestMkReactionFilters(t *testing.T) {
+	ids := []string{}
+	for i := 0; i < reactionBatchSize*2+1; i++ {
+		ids = append(ids, "id")
+	}
+	filters := mkReactionFilters(ids)
+	if len(filters) != 3 {
+		t.Fatalf("got filters: %v, Want filters: 3", len(filters))
+	}
+	if len(filters[2].Tags["e"]) != 1 {
+		t.Fatalf("got ids in last filter: %v, Want: 1", len(filters[2].Tags["e"]))
+	}
+}
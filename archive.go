@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"io"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	kindsOption = "--kinds"
+	exportPage  = 500
+	importDir   = "import"
+	importExt   = ".json"
+)
+
+/*
+	export {{{
+		[infomation for develop]
+		usage:
+			nostk export [--author npub] [--kinds 1,7,...] > backup.jsonl
+		Your own events by default.
+*/
+func export(args []string, cc confClass) error {
+	args, author, hasAuthor, err := extractOption(args, authorOption)
+	if err != nil {
+		return err
+	}
+	args, strKinds, err := extractOptions(args, kindsOption)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("Wrong number of parameters")
+	}
+
+	filter := nostr.Filter{}
+	if hasAuthor {
+		pk, err := toHexId(author, "npub", "nprofile")
+		if err != nil {
+			return err
+		}
+		filter.Authors = []string{pk}
+	} else {
+		pk, err := cc.getMySelfHexPubkey()
+		if err != nil {
+			return err
+		}
+		filter.Authors = []string{pk}
+	}
+	if filter.Kinds, err = parseKinds(strKinds); err != nil {
+		return err
+	}
+
+	var rs []string
+	if err := cc.getRelayList(&rs, readFlag); err != nil {
+		return err
+	}
+	n, err := exportEvents(cc, rs, filter, os.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d events\n", n)
+	return nil
+}
+
+// parseKinds parses "--kinds 1,7 --kinds 30023".
+func parseKinds(values []string) ([]int, error) {
+	kinds := []int{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			k, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || k < 0 {
+				return nil, fmt.Errorf("Invalid kind %q", s)
+			}
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds, nil
+}
+
+// }}}
+
+/* exportEvents {{{
+
+WHAT'S THIS?
+Reads every event matching filter from rs, paging backwards with
+"until" until the relays are exhausted, and writes them to w as JSONL
+sorted by created_at. Events are verified by subManyEose and
+deduplicated by fetchAllEvents.
+*/
+func exportEvents(cc confClass, rs []string, filter nostr.Filter, w io.Writer) (int, error) {
+	evs := []*nostr.Event{}
+	fetchAllEvents(cc, rs, filter, exportPage, 0, func(page []nostr.RelayEvent) bool {
+		if len(page) < 1 {
+			return true
+		}
+		oldest := page[0].CreatedAt
+		for _, rev := range page {
+			evs = append(evs, rev.Event)
+			if rev.CreatedAt < oldest {
+				oldest = rev.CreatedAt
+			}
+		}
+		fmt.Fprintf(os.Stderr, "fetched %d events (back to %v)\n", len(evs), oldest.Time().Format(layout))
+		return true
+	})
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].CreatedAt < evs[j].CreatedAt
+	})
+	for _, ev := range evs {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := fmt.Fprintln(w, string(b)); err != nil {
+			return 0, err
+		}
+	}
+	return len(evs), nil
+}
+
+// }}}
+
+/*
+import state structure {{{
+
+Kept in ~/.nostk/import while an archive is imported, so that an
+interrupted import starts where it stopped. Done is the number of
+events of the archive that were sent; Failed lists the relays of each
+event that may accept it when tried again.
+*/
+type ImportState struct {
+	File   string              `json:"file"`
+	Relays []string            `json:"relays"`
+	Done   int                 `json:"done"`
+	Failed map[string][]string `json:"failed,omitempty"`
+}
+
+func (cc *confClass) importStatePath(file string, rl []string) (string, error) {
+	d, err := cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	d = filepath.Join(d, importDir)
+	if err := os.MkdirAll(d, 0700); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	sorted := append([]string{}, rl...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(abs + "\n" + strings.Join(sorted, "\n")))
+	return filepath.Join(d, hex.EncodeToString(sum[:8])+importExt), nil
+}
+func loadImportState(path string) (ImportState, bool, error) {
+	var st ImportState
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, false, nil
+	} else if err != nil {
+		return st, false, err
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return st, false, fmt.Errorf("%v: %v", path, err)
+	}
+	return st, true, nil
+}
+func saveImportState(path string, st ImportState) error {
+	b, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// }}}
+
+/*
+	importArchive {{{
+		[infomation for develop]
+		usage:
+			nostk import <file> [--to url...]
+		Publishes the events of an export archive unchanged to the write
+		relays or to --to. Run it again to resume an interrupted import or
+		to retry the relays that failed.
+*/
+func importArchive(args []string, cc confClass) error {
+	if !cc.publishes() {
+		return errPublishMode
+	}
+	args, to, err := extractOptions(args, toOption)
+	if err != nil {
+		return err
+	}
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	var rl []string
+	if 0 < len(to) {
+		rl = uniqueRelays(to)
+	} else if err := cc.getRelayList(&rl, writeFlag); err != nil {
+		return err
+	}
+	if len(rl) < 1 {
+		return errors.New("No relays to publish to")
+	}
+
+	f, err := os.Open(args[2])
+	if err != nil {
+		return err
+	}
+	evs, err := readSignedEvents(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	path, err := cc.importStatePath(args[2], rl)
+	if err != nil {
+		return err
+	}
+	st, resumed, err := loadImportState(path)
+	if err != nil {
+		return err
+	}
+	if !resumed {
+		st = ImportState{File: args[2], Relays: rl}
+	}
+	if st.Failed == nil {
+		st.Failed = map[string][]string{}
+	}
+	if len(evs) < st.Done {
+		return fmt.Errorf("%v has fewer events than the last import. Remove %v to start again.", args[2], path)
+	}
+	if resumed {
+		fmt.Fprintf(os.Stderr, "resuming: %d of %d events sent, %d to retry\n", st.Done, len(evs), len(st.Failed))
+	}
+
+	p := newPublisher(cc)
+	defer p.close()
+	progress := isTerminal(os.Stderr)
+	counts := map[string]int{}
+	send := func(ev nostr.Event, rl []string) {
+		delete(st.Failed, ev.ID)
+		for _, res := range p.publish(ev, rl) {
+			switch {
+			case res.Ok:
+				counts[res.Status]++
+			case res.retryable():
+				counts[resultFailed]++
+				st.Failed[ev.ID] = append(st.Failed[ev.ID], res.Relay)
+			default:
+				counts[res.Status]++
+				fmt.Fprintf(os.Stderr, "%v %v by %v: %v\n", ev.ID, res.Status, res.Relay, res.Message)
+			}
+		}
+	}
+
+	// retry first, then continue from where the last run stopped
+	byId := make(map[string]nostr.Event)
+	for _, ev := range evs[:st.Done] {
+		byId[ev.ID] = ev
+	}
+	retries := st.Failed
+	st.Failed = map[string][]string{}
+	for id, relays := range retries {
+		if ev, ok := byId[id]; ok {
+			send(ev, relays)
+		}
+	}
+	if err := saveImportState(path, st); err != nil {
+		return err
+	}
+	for i := st.Done; i < len(evs); i++ {
+		send(evs[i], rl)
+		st.Done = i + 1
+		if err := saveImportState(path, st); err != nil {
+			return err
+		}
+		if progress {
+			fmt.Fprintf(os.Stderr, "\r%d/%d events", st.Done, len(evs))
+		}
+	}
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	fmt.Printf("imported %d events to %d relays: %d accepted, %d duplicate, %d rejected, %d skipped, %d failed\n",
+		len(evs), len(rl), counts[resultAccepted], counts[resultDuplicate], counts[resultRejected], counts[resultSkipped], counts[resultFailed])
+	if 0 < len(st.Failed) {
+		return fmt.Errorf("%d events failed on some relays. Run import again to retry them.", len(st.Failed))
+	}
+	return os.Remove(path)
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseKinds(t *testing.T) {
+	kinds, err := parseKinds([]string{"1,7", " 30023"})
+	if err != nil || !reflect.DeepEqual(kinds, []int{1, 7, 30023}) {
+		t.Errorf("parseKinds = %v, %v", kinds, err)
+	}
+	if _, err := parseKinds([]string{"1,x"}); err == nil {
+		t.Error("invalid kind was accepted")
+	}
+}
+
+func TestExportEvents(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	mk := func(kind int, at nostr.Timestamp, content string) nostr.Event {
+		ev := nostr.Event{Kind: kind, CreatedAt: at, Content: content, Tags: nostr.Tags{}}
+		ev.Sign(sk)
+		return ev
+	}
+	a := mk(1, 3000, "c")
+	b := mk(1, 1000, "a")
+	c := mk(7, 2000, "+")
+	forged := mk(1, 2500, "original")
+	forged.Content = "forged"
+
+	// two relays with overlapping events
+	r1 := newTestRelay(t, &testRelay{Stored: []nostr.Event{a, forged, c}}).URL
+	r2 := newTestRelay(t, &testRelay{Stored: []nostr.Event{a, b}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+
+	var out bytes.Buffer
+	n, err := exportEvents(cc, []string{r1, r2}, nostr.Filter{Authors: []string{pk}}, &out)
+	if err != nil || n != 3 {
+		t.Fatalf("exportEvents = %d, %v", n, err)
+	}
+	evs, err := readSignedEvents(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, ev := range evs {
+		ids = append(ids, ev.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{b.ID, c.ID, a.ID}) {
+		t.Errorf("exported %v", evs)
+	}
+
+	out.Reset()
+	if n, _ := exportEvents(cc, []string{r1, r2}, nostr.Filter{Authors: []string{pk}, Kinds: []int{7}}, &out); n != 1 {
+		t.Errorf("kind 7 export = %d events", n)
+	}
+}
+
+func TestExportEventsPages(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	mk := func(at nostr.Timestamp) nostr.Event {
+		ev := nostr.Event{Kind: 1, CreatedAt: at, Content: "note", Tags: nostr.Tags{}}
+		ev.Sign(sk)
+		return ev
+	}
+	// more than one page on the newer relay, and an older relay that
+	// shares some of its events
+	recent := []nostr.Event{}
+	for i := 0; i < exportPage+100; i++ {
+		recent = append(recent, mk(nostr.Timestamp(10000+i)))
+	}
+	old := append([]nostr.Event{}, recent[:50]...)
+	for i := 0; i < 300; i++ {
+		old = append(old, mk(nostr.Timestamp(1+i)))
+	}
+	r1 := newTestRelay(t, &testRelay{Stored: recent}).URL
+	r2 := newTestRelay(t, &testRelay{Stored: old}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+
+	var out bytes.Buffer
+	n, err := exportEvents(cc, []string{r1, r2}, nostr.Filter{Authors: []string{pk}}, &out)
+	if err != nil || n != exportPage+400 {
+		t.Fatalf("exportEvents = %d, %v, want %d", n, err, exportPage+400)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != n {
+		t.Errorf("wrote %d lines", lines)
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	var mu sync.Mutex
+	received := []string{}
+	var limited atomic.Bool
+	limited.Store(true)
+	url := newTestRelay(t, &testRelay{Reply: func(ev nostr.Event) (bool, string) {
+		if ev.Content == "limited" && limited.Load() {
+			return false, "rate-limited: slow down"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, ev.Content)
+		return true, ""
+	}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{url: {Write: true}})
+	cc.ConfData.Settings.ConnectTimeout = "1s"
+	cc.ConfData.Settings.OkTimeout = "1s"
+
+	lines := []string{}
+	for _, s := range []string{"one", "limited", "two", "three"} {
+		b, _ := json.Marshal(signedEvent(t, s))
+		lines = append(lines, string(b))
+	}
+	file := filepath.Join(t.TempDir(), "backup.jsonl")
+	os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0600)
+	path, _ := cc.importStatePath(file, []string{nostr.NormalizeURL(url)})
+
+	// an import stopped after the first event
+	var first nostr.Event
+	json.Unmarshal([]byte(lines[0]), &first)
+	saveImportState(path, ImportState{File: file, Relays: []string{url}, Done: 1})
+
+	args := []string{"nostk", "import", file, "--to", url}
+	if err := importArchive(args, cc); err == nil {
+		t.Error("no error with a failed event")
+	}
+	if got := strings.Join(received, ","); got != "two,three" {
+		t.Errorf("resumed import sent %v", got)
+	}
+	st, ok, err := loadImportState(path)
+	if !ok || err != nil || st.Done != 4 || len(st.Failed) != 1 {
+		t.Fatalf("state = %+v, %v, %v", st, ok, err)
+	}
+
+	// the next run retries only the failed event
+	limited.Store(false)
+	if err := importArchive(args, cc); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(received, ","); got != "two,three,limited" {
+		t.Errorf("retry sent %v", got)
+	}
+	if _, ok, _ := loadImportState(path); ok {
+		t.Error("state is kept after a complete import")
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	draftFlag = "--draft"
+)
+
+/*
+article front matter structure {{{
+*/
+type ArticleMeta struct {
+	Title       string
+	Summary     string
+	Image       string
+	Identifier  string
+	PublishedAt int64
+	Tags        []string
+}
+
+// }}}
+
+/* parseFrontMatter {{{
+
+WHAT'S THIS?
+Splits a Markdown file into the front matter and the body.
+Only the simple subset of YAML used by front matter is supported.
+
+	---
+	title: My article
+	summary: "one line"
+	image: https://example.com/cover.png
+	d: my-article          (also "identifier" or "slug")
+	published_at: 1700000000
+	tags: [nostr, go]      (or "- item" lines)
+	---
+*/
+func parseFrontMatter(src string) (ArticleMeta, string, error) {
+	var meta ArticleMeta
+	header, body, err := splitFrontMatter(src)
+	if err != nil {
+		return meta, src, err
+	}
+
+	key := ""
+	for _, line := range header {
+		if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && key == "tags" {
+			meta.Tags = append(meta.Tags, unquote(item))
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return meta, src, fmt.Errorf("Invalid front matter line: %v", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		switch key {
+		case "title":
+			meta.Title = unquote(v)
+		case "summary":
+			meta.Summary = unquote(v)
+		case "image":
+			meta.Image = unquote(v)
+		case "d", "identifier", "slug":
+			meta.Identifier = unquote(v)
+		case "published_at":
+			ut, err := strconv.ParseInt(unquote(v), 10, 64)
+			if err != nil {
+				return meta, src, fmt.Errorf("Invalid published_at: %v", v)
+			}
+			meta.PublishedAt = ut
+		case "tags":
+			v = strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+			for _, t := range strings.Split(v, ",") {
+				if t = unquote(t); 0 < len(t) {
+					meta.Tags = append(meta.Tags, t)
+				}
+			}
+		}
+	}
+	return meta, body, nil
+}
+
+// }}}
+
+/* splitFrontMatter {{{
+
+WHAT'S THIS?
+Returns the lines between the leading "---" markers and the rest.
+Blank lines and "#" comments of the header are dropped.
+Without a leading "---" the whole source is the body.
+*/
+func splitFrontMatter(src string) ([]string, string, error) {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return nil, src, nil
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, src, errors.New("Front matter is not closed with \"---\"")
+	}
+
+	header := []string{}
+	for _, line := range lines[1:end] {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		header = append(header, line)
+	}
+	body := strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+	return header, body, nil
+}
+
+// }}}
+
+/* unquote {{{
+ */
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if 2 <= len(s) && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// }}}
+
+/* ArticleMeta.tags {{{
+ */
+func (r ArticleMeta) tags(publishedAt int64) nostr.Tags {
+	tgs := nostr.Tags{{"d", r.Identifier}}
+	if 0 < len(r.Title) {
+		tgs = append(tgs, nostr.Tag{"title", r.Title})
+	}
+	if 0 < len(r.Summary) {
+		tgs = append(tgs, nostr.Tag{"summary", r.Summary})
+	}
+	if 0 < len(r.Image) {
+		tgs = append(tgs, nostr.Tag{"image", r.Image})
+	}
+	if 0 < publishedAt {
+		tgs = append(tgs, nostr.Tag{"published_at", strconv.FormatInt(publishedAt, 10)})
+	}
+	for _, t := range r.Tags {
+		tgs = append(tgs, nostr.Tag{"t", strings.ToLower(strings.TrimLeft(t, "#"))})
+	}
+	return tgs
+}
+
+// }}}
+
+/* getTagValue {{{
+ */
+func getTagValue(tgs nostr.Tags, name string) string {
+	if tg := tgs.Find(name); tg != nil {
+		return tg[1]
+	}
+	return ""
+}
+
+// }}}
+
+/*
+	pubArticle {{{
+		[infomation for develop]
+		usage:
+			nostk pubArticle <file.md> [--draft]
+		kind: 30023 (30024 with --draft)
+		content: Markdown
+		tags [
+			"d": identifier (front matter or file name)
+			"title", "summary", "image": front matter (optional)
+			"published_at": first publish time, kept on updates
+				(fails when no read relay answers, unless set in the front matter)
+			"t": front matter tags
+		]
+*/
+func pubArticle(args []string, cc confClass) error {
+	args, draft := extractFlag(args, draftFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	b, err := os.ReadFile(args[2])
+	if err != nil {
+		return err
+	}
+	meta, body, err := parseFrontMatter(string(b))
+	if err != nil {
+		return err
+	}
+	if meta.Identifier == "" {
+		base := filepath.Base(args[2])
+		meta.Identifier = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	kind := nostr.KindArticle
+	var publishedAt int64
+	if draft {
+		kind = nostr.KindDraftArticle
+	} else {
+		publishedAt = meta.PublishedAt
+		if publishedAt == 0 {
+			var rs []string
+			if err := cc.getRelayList(&rs, readFlag); err != nil {
+				return err
+			}
+			ev, answered := fetchLatestAnswered(cc, rs, nostr.Filter{
+				Kinds:   []int{nostr.KindArticle},
+				Authors: []string{pk},
+				Tags:    nostr.TagMap{"d": []string{meta.Identifier}},
+				Limit:   singleReadNo,
+			})
+			if ev != nil {
+				publishedAt, _ = strconv.ParseInt(getTagValue(ev.Tags, "published_at"), 10, 64)
+			} else if answered < 1 {
+				// publishing now would reset published_at of an existing article
+				return errors.New("No read relay answered whether the article is already published. Try again, or set published_at in the front matter")
+			}
+		}
+		if publishedAt == 0 {
+			publishedAt = time.Now().Unix()
+		}
+	}
+
+	dataRawArg := RawArg{
+		Kind:    kind,
+		Content: body,
+		Tags:    meta.tags(publishedAt),
+	}
+	if err := publishRawArg(dataRawArg, checkedTags, cc); err != nil {
+		return err
+	}
+	if naddr, err := nip19.EncodeEntity(pk, kind, meta.Identifier, nil); err == nil {
+		fmt.Printf("article address: %v\n", naddr)
+	}
+	return nil
+}
+
+// }}}
+
+/*
+article list structure {{{
+*/
+type ArticleInfo struct {
+	Naddr       string   `json:"naddr"`
+	Identifier  string   `json:"identifier"`
+	Title       string   `json:"title"`
+	Summary     string   `json:"summary,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	PublishedAt string   `json:"published_at,omitempty"`
+	UpdatedAt   string   `json:"updated_at"`
+	Tags        []string `json:"tags,omitempty"`
+	Content     string   `json:"content,omitempty"`
+}
+
+// }}}
+
+/* newArticleInfo {{{
+ */
+func newArticleInfo(ev *nostr.Event, withContent bool) ArticleInfo {
+	d := getTagValue(ev.Tags, "d")
+	info := ArticleInfo{
+		Identifier: d,
+		Title:      getTagValue(ev.Tags, "title"),
+		Summary:    getTagValue(ev.Tags, "summary"),
+		Image:      getTagValue(ev.Tags, "image"),
+		UpdatedAt:  ev.CreatedAt.Time().Format(layout),
+	}
+	if naddr, err := nip19.EncodeEntity(ev.PubKey, ev.Kind, d, nil); err == nil {
+		info.Naddr = naddr
+	}
+	if ut, err := strconv.ParseInt(getTagValue(ev.Tags, "published_at"), 10, 64); err == nil {
+		info.PublishedAt = time.Unix(ut, 0).Format(layout)
+	}
+	for _, tg := range ev.Tags {
+		if 1 < len(tg) && tg[indexTagName] == "t" {
+			info.Tags = append(info.Tags, tg[1])
+		}
+	}
+	if withContent {
+		info.Content = ev.Content
+	}
+	return info
+}
+
+// }}}
+
+/* latestArticles {{{
+
+WHAT'S THIS?
+Keeps only the newest version of each article (same author and "d").
+*/
+func latestArticles(evs []*nostr.Event) []*nostr.Event {
+	latest := make(map[string]*nostr.Event)
+	for _, ev := range evs {
+		key := ev.PubKey + ":" + getTagValue(ev.Tags, "d")
+		if v, ok := latest[key]; !ok || v.CreatedAt < ev.CreatedAt {
+			latest[key] = ev
+		}
+	}
+	ret := []*nostr.Event{}
+	for _, ev := range latest {
+		ret = append(ret, ev)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CreatedAt > ret[j].CreatedAt
+	})
+	return ret
+}
+
+// }}}
+
+/*
+	catArticles {{{
+		[infomation for develop]
+		usage:
+			nostk catArticles [npub|naddr] [number] [--draft]
+		With naddr, the article itself is displayed.
+		Otherwise articles of the author (yourself by default) are listed.
+*/
+func catArticles(args []string, cc confClass) error {
+	args, draft := extractFlag(args, draftFlag)
+	kind := nostr.KindArticle
+	if draft {
+		kind = nostr.KindDraftArticle
+	}
+	num := cc.getConf().Settings.DefaultReadNo
+
+	var filter nostr.Filter
+	withContent := false
+	target := ""
+	if 3 <= len(args) {
+		target = args[2]
+	}
+	if 4 <= len(args) {
+		tmpnum, err := strconv.Atoi(args[3])
+		if err != nil {
+			return errors.New("An unknown argument was specified.")
+		}
+		num = tmpnum
+	}
+	if 4 < len(args) {
+		return errors.New("Too meny argument")
+	}
+
+	switch {
+	case strings.HasPrefix(target, "naddr"):
+		ep, err := toEntityPointer(target)
+		if err != nil {
+			return err
+		}
+		filter = ep.AsFilter()
+		withContent = true
+		num = singleReadNo
+	default:
+		var pk string
+		var err error
+		if target == "" {
+			pk, err = cc.getMySelfHexPubkey()
+		} else if tmpnum, cerr := strconv.Atoi(target); cerr == nil {
+			num = tmpnum
+			pk, err = cc.getMySelfHexPubkey()
+		} else {
+			pk, err = toHexId(target, "npub", "nprofile")
+		}
+		if err != nil {
+			return err
+		}
+		filter = nostr.Filter{
+			Kinds:   []int{kind},
+			Authors: []string{pk},
+			Limit:   num,
+		}
+	}
+
+	evs, err := fetchEvents(cc, nostr.Filters{filter}, num)
+	if err != nil {
+		return err
+	}
+	mf := loadMuteFilter(cc)
+	infos := []ArticleInfo{}
+	for _, ev := range latestArticles(evs) {
+		if mf.isMuted(ev) {
+			continue
+		}
+		infos = append(infos, newArticleInfo(ev, withContent))
+	}
+	return printJson(infos)
+}
+
+// }}}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		meta ArticleMeta
+		body string
+		err  bool
+	}{
+		{
+			name: "inline tags",
+			src:  "---\ntitle: \"Hello, nostr\"\nsummary: short\nslug: hello\npublished_at: 1700000000\ntags: [nostr, 'go']\n---\n\n# Hello\n",
+			meta: ArticleMeta{Title: "Hello, nostr", Summary: "short", Identifier: "hello", PublishedAt: 1700000000, Tags: []string{"nostr", "go"}},
+			body: "# Hello\n",
+		},
+		{
+			name: "list tags",
+			src:  "---\r\ntitle: List\r\ntags:\r\n  - a\r\n  - b\r\nimage: https://example.com/a.png\r\n---\r\nbody",
+			meta: ArticleMeta{Title: "List", Image: "https://example.com/a.png", Tags: []string{"a", "b"}},
+			body: "body",
+		},
+		{
+			name: "no front matter",
+			src:  "# Only body\n",
+			body: "# Only body\n",
+		},
+		{
+			name: "not closed",
+			src:  "---\ntitle: x\n",
+			err:  true,
+		},
+		{
+			name: "bad published_at",
+			src:  "---\npublished_at: yesterday\n---\n",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		meta, body, err := parseFrontMatter(tt.src)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%v: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(meta, tt.meta) {
+			t.Errorf("%v: meta = %+v, want %+v", tt.name, meta, tt.meta)
+		}
+		if body != tt.body {
+			t.Errorf("%v: body = %q, want %q", tt.name, body, tt.body)
+		}
+	}
+}
+
+func TestPubArticleKeepsPublishedAt(t *testing.T) {
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc := setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+	cc.ConfData.Settings.MinSuccess = 1
+	file := filepath.Join(t.TempDir(), "hello.md")
+
+	// without an answer the first publish time is unknown
+	os.WriteFile(file, []byte("---\ntitle: Hello\n---\nbody\n"), 0600)
+	if err := pubArticle([]string{"nostk", "pubArticle", file}, cc); err == nil {
+		t.Error("published without published_at")
+	}
+	os.WriteFile(file, []byte("---\ntitle: Hello\npublished_at: 1700000000\n---\nbody\n"), 0600)
+	if err := pubArticle([]string{"nostk", "pubArticle", file}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if n := silent.Received.Load(); n != 1 {
+		t.Errorf("relay received %d articles", n)
+	}
+}
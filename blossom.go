@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	//"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	blossomAuthExpiration = 5 * time.Minute
+	blossomTimeout        = 60 * time.Second
+)
+
+/*
+blob descriptor structure (BUD-02) {{{
+*/
+type BlobDescriptor struct {
+	Url      string `json:"url"`
+	Sha256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Type     string `json:"type,omitempty"`
+	Uploaded int64  `json:"uploaded"`
+}
+
+// }}}
+
+/*
+Blossom client {{{
+
+WHAT'S THIS?
+Minimal Blossom (BUD-01, BUD-02 and BUD-04) client.
+Every request is authorized with a kind 24242 event signed by our key.
+*/
+type BlossomClient struct {
+	server string
+	sk     string
+	pk     string
+	client *http.Client
+}
+
+func newBlossomClient(server string, sk string) (*BlossomClient, error) {
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	return &BlossomClient{
+		server: strings.TrimRight(server, "/"),
+		sk:     sk,
+		pk:     pk,
+		client: &http.Client{Timeout: blossomTimeout},
+	}, nil
+}
+
+/* BlossomClient.authorization {{{
+
+WHAT'S THIS?
+Builds the "Authorization" header value.
+	kind: 24242
+	content: human readable description
+	tags [
+		"t": upload, list or delete
+		"expiration": unix time
+		"x": sha256 of the blob (upload and delete)
+	]
+*/
+func (c *BlossomClient) authorization(verb string, content string, hashes ...string) (string, error) {
+	tgs := nostr.Tags{
+		{"t", verb},
+		{"expiration", fmt.Sprintf("%d", time.Now().Add(blossomAuthExpiration).Unix())},
+	}
+	for _, h := range hashes {
+		tgs = append(tgs, nostr.Tag{"x", h})
+	}
+	ev := nostr.Event{
+		PubKey:    c.pk,
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindBlobs,
+		Tags:      tgs,
+		Content:   content,
+	}
+	if err := ev.Sign(c.sk); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+	return "Nostr " + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// }}}
+
+/* BlossomClient.do {{{
+ */
+func (c *BlossomClient) do(req *http.Request, ret interface{}) error {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || 299 < res.StatusCode {
+		reason := res.Header.Get("X-Reason")
+		if reason == "" {
+			reason = res.Status
+		}
+		return fmt.Errorf("%v %v : %v", req.Method, req.URL, reason)
+	}
+	if ret == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(ret)
+}
+
+// }}}
+
+/* BlossomClient.upload {{{
+
+BUD-02: PUT /upload
+*/
+func (c *BlossomClient) upload(fn string) (BlobDescriptor, error) {
+	var bd BlobDescriptor
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return bd, err
+	}
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+
+	auth, err := c.authorization("upload", "Upload "+path.Base(fn), hash)
+	if err != nil {
+		return bd, err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.server+"/upload", bytes.NewReader(b))
+	if err != nil {
+		return bd, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", http.DetectContentType(b))
+	if err := c.do(req, &bd); err != nil {
+		return bd, err
+	}
+	if bd.Sha256 != hash {
+		return bd, fmt.Errorf("%v returned a different hash %v", c.server, bd.Sha256)
+	}
+	return bd, nil
+}
+
+// }}}
+
+/* BlossomClient.list {{{
+
+BUD-02: GET /list/<pubkey>
+*/
+func (c *BlossomClient) list() ([]BlobDescriptor, error) {
+	bds := []BlobDescriptor{}
+	auth, err := c.authorization("list", "List blobs")
+	if err != nil {
+		return bds, err
+	}
+	req, err := http.NewRequest(http.MethodGet, c.server+"/list/"+c.pk, nil)
+	if err != nil {
+		return bds, err
+	}
+	req.Header.Set("Authorization", auth)
+	err = c.do(req, &bds)
+	return bds, err
+}
+
+// }}}
+
+/* BlossomClient.delete {{{
+
+BUD-02: DELETE /<sha256>
+*/
+func (c *BlossomClient) delete(hash string) error {
+	auth, err := c.authorization("delete", "Delete "+hash, hash)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, c.server+"/"+hash, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	return c.do(req, nil)
+}
+
+// }}}
+
+/* BlossomClient.mirror {{{
+
+BUD-04: PUT /mirror
+*/
+func (c *BlossomClient) mirror(url string, hash string) (BlobDescriptor, error) {
+	var bd BlobDescriptor
+	auth, err := c.authorization("upload", "Mirror "+hash, hash)
+	if err != nil {
+		return bd, err
+	}
+	body, err := json.Marshal(map[string]string{"url": url})
+	if err != nil {
+		return bd, err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.server+"/mirror", bytes.NewReader(body))
+	if err != nil {
+		return bd, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	err = c.do(req, &bd)
+	return bd, err
+}
+
+// }}}
+
+// }}}
+
+/* getBlobHash {{{
+
+WHAT'S THIS?
+Returns the sha256 of a blob given as a hash or as a Blossom URL
+("https://server/<sha256>.ext").
+*/
+func getBlobHash(s string) (string, error) {
+	base := path.Base(s)
+	hash := strings.TrimSuffix(base, path.Ext(base))
+	if is64HexString(hash) == false {
+		return "", fmt.Errorf("Not found sha256 in %v", s)
+	}
+	return strings.ToLower(hash), nil
+}
+
+// }}}
+
+/* getBlossomServers {{{
+ */
+func (cc *confClass) getBlossomServers() ([]string, error) {
+	servers := []string{}
+	b, err := cc.load(cc.ConfData.Filename.Blossom)
+	if err != nil {
+		fmt.Println("Not found Blossom server list. Use \"nostk init\" and \"nostk editBlossom\".")
+		return servers, err
+	}
+	if err := json5.Unmarshal([]byte(b), &servers); err != nil {
+		return servers, err
+	}
+	if len(servers) < 1 {
+		return servers, errors.New("Blossom server list is empty. Use \"nostk editBlossom\".")
+	}
+	return servers, nil
+}
+
+// }}}
+
+/* newBlossomClients {{{
+
+WHAT'S THIS?
+Returns clients for the servers given on the command line, or for the
+configured server list when none is given.
+*/
+func newBlossomClients(servers []string, cc confClass) ([]*BlossomClient, error) {
+	var err error
+	if len(servers) < 1 {
+		if servers, err = cc.getBlossomServers(); err != nil {
+			return nil, err
+		}
+	}
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Println("Nothing key pair. Make key pair.")
+		return nil, err
+	}
+	clients := []*BlossomClient{}
+	for _, s := range servers {
+		c, err := newBlossomClient(s, sk)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, nil
+}
+
+// }}}
+
+/* uploadAndMirror {{{
+
+WHAT'S THIS?
+Uploads a file to the first server and mirrors it to the others.
+Failing mirrors are reported but do not fail the upload.
+*/
+func uploadAndMirror(clients []*BlossomClient, fn string) (BlobDescriptor, error) {
+	bd, err := clients[0].upload(fn)
+	if err != nil {
+		return bd, err
+	}
+	for _, c := range clients[1:] {
+		if _, err := c.mirror(bd.Url, bd.Sha256); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return bd, nil
+}
+
+// }}}
+
+/* blossomUploader {{{
+
+WHAT'S THIS?
+Uploader for pubPicture. Selected by "uploader" : "blossom".
+*/
+type blossomUploader struct {
+	clients []*BlossomClient
+}
+
+func newBlossomUploader(cc confClass) (Uploader, error) {
+	clients, err := newBlossomClients(nil, cc)
+	if err != nil {
+		return nil, err
+	}
+	return &blossomUploader{clients: clients}, nil
+}
+func (r *blossomUploader) upload(info ImageInfo) (string, error) {
+	bd, err := uploadAndMirror(r.clients, info.Path)
+	if err != nil {
+		return "", err
+	}
+	return bd.Url, nil
+}
+
+// }}}
+
+/*
+	blossomUpload {{{
+		[infomation for develop]
+		usage:
+			nostk blossomUpload <file...>
+*/
+func blossomUpload(args []string, cc confClass) error {
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+	clients, err := newBlossomClients(nil, cc)
+	if err != nil {
+		return err
+	}
+	bds := []BlobDescriptor{}
+	for _, fn := range args[2:] {
+		bd, err := uploadAndMirror(clients, fn)
+		if err != nil {
+			return err
+		}
+		bds = append(bds, bd)
+	}
+	return printJson(bds)
+}
+
+// }}}
+
+/*
+	blossomList {{{
+		[infomation for develop]
+		usage:
+			nostk blossomList [server]
+*/
+func blossomList(args []string, cc confClass) error {
+	if 3 < len(args) {
+		return errors.New("Too meny argument")
+	}
+	clients, err := newBlossomClients(args[2:], cc)
+	if err != nil {
+		return err
+	}
+	ret := make(map[string][]BlobDescriptor)
+	var errs []error
+	for _, c := range clients {
+		bds, err := c.list()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret[c.server] = bds
+	}
+	if err := printJson(ret); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+/*
+	blossomDelete {{{
+		[infomation for develop]
+		usage:
+			nostk blossomDelete <sha256|url> [server...]
+*/
+func blossomDelete(args []string, cc confClass) error {
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+	hash, err := getBlobHash(args[2])
+	if err != nil {
+		return err
+	}
+	clients, err := newBlossomClients(args[3:], cc)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range clients {
+		if err := c.delete(hash); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("deleted from %s\n", c.server)
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+/*
+	blossomMirror {{{
+		[infomation for develop]
+		usage:
+			nostk blossomMirror <url> [server...]
+*/
+func blossomMirror(args []string, cc confClass) error {
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+	url := args[2]
+	hash, err := getBlobHash(url)
+	if err != nil {
+		return err
+	}
+	clients, err := newBlossomClients(args[3:], cc)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range clients {
+		if strings.HasPrefix(url, c.server+"/") {
+			continue
+		}
+		if _, err := c.mirror(url, hash); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("mirrored to %s\n", c.server)
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+/*
+	pubBlossom {{{
+		[infomation for develop]
+		usage:
+			nostk pubBlossom
+		kind: 10063
+		content: ""
+		tags [
+			"server": url (in order of preference)
+		]
+*/
+func pubBlossom(args []string, cc confClass) error {
+	servers, err := cc.getBlossomServers()
+	if err != nil {
+		return err
+	}
+	dataRawArg := RawArg{
+		Kind:    nostr.KindUserServerList,
+		Content: "",
+		Tags:    nostr.Tags{},
+	}
+	for _, s := range servers {
+		dataRawArg.Tags = append(dataRawArg.Tags, nostr.Tag{"server", s})
+	}
+	return publishRawArg(dataRawArg, checkedTags, cc)
+}
+
+// }}}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/nbd-wtf/go-nostr"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+Blossom stand-in for tests.
+Keeps blobs in memory and checks the kind 24242 authorization.
+*/
+type blossomStandIn struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	owner map[string]string
+	srv   *httptest.Server
+}
+
+func newBlossomStandIn() *blossomStandIn {
+	b := &blossomStandIn{blobs: map[string][]byte{}, owner: map[string]string{}}
+	b.srv = httptest.NewServer(http.HandlerFunc(b.handle))
+	return b
+}
+
+func (b *blossomStandIn) auth(r *http.Request, verb string, hash string) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Nostr ") {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "Nostr "))
+	if err != nil {
+		return "", false
+	}
+	var ev nostr.Event
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return "", false
+	}
+	if ok, err := ev.CheckSignature(); !ok || err != nil || ev.Kind != nostr.KindBlobs {
+		return "", false
+	}
+	if t := ev.Tags.Find("t"); t == nil || t[1] != verb {
+		return "", false
+	}
+	if hash != "" {
+		if x := ev.Tags.Find("x"); x == nil || x[1] != hash {
+			return "", false
+		}
+	}
+	return ev.PubKey, true
+}
+
+func (b *blossomStandIn) store(w http.ResponseWriter, pk string, data []byte) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	b.mu.Lock()
+	b.blobs[hash] = data
+	b.owner[hash] = pk
+	b.mu.Unlock()
+	json.NewEncoder(w).Encode(BlobDescriptor{Url: b.srv.URL + "/" + hash, Sha256: hash, Size: int64(len(data))})
+}
+
+func (b *blossomStandIn) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/upload":
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		pk, ok := b.auth(r, "upload", hex.EncodeToString(sum[:]))
+		if !ok {
+			w.Header().Set("X-Reason", "invalid auth")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b.store(w, pk, data)
+	case r.Method == http.MethodPut && r.URL.Path == "/mirror":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		res, err := http.Get(body["url"])
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		data, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		sum := sha256.Sum256(data)
+		pk, ok := b.auth(r, "upload", hex.EncodeToString(sum[:]))
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b.store(w, pk, data)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/list/"):
+		if _, ok := b.auth(r, "list", ""); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		pk := strings.TrimPrefix(r.URL.Path, "/list/")
+		bds := []BlobDescriptor{}
+		b.mu.Lock()
+		for hash, data := range b.blobs {
+			if b.owner[hash] == pk {
+				bds = append(bds, BlobDescriptor{Url: b.srv.URL + "/" + hash, Sha256: hash, Size: int64(len(data))})
+			}
+		}
+		b.mu.Unlock()
+		json.NewEncoder(w).Encode(bds)
+	case r.Method == http.MethodDelete:
+		hash := strings.TrimPrefix(r.URL.Path, "/")
+		if _, ok := b.auth(r, "delete", hash); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b.mu.Lock()
+		delete(b.blobs, hash)
+		b.mu.Unlock()
+	case r.Method == http.MethodGet:
+		b.mu.Lock()
+		data, ok := b.blobs[strings.TrimPrefix(r.URL.Path, "/")]
+		b.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBlossomClient(t *testing.T) {
+	primary := newBlossomStandIn()
+	defer primary.srv.Close()
+	secondary := newBlossomStandIn()
+	defer secondary.srv.Close()
+
+	sk, _, err := genHexKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := []*BlossomClient{}
+	for _, s := range []string{primary.srv.URL + "/", secondary.srv.URL} {
+		c, err := newBlossomClient(s, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, c)
+	}
+
+	fn := filepath.Join(t.TempDir(), "blob.txt")
+	if err := os.WriteFile(fn, []byte("hello blossom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bd, err := uploadAndMirror(clients, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := getBlobHash(bd.Url); err != nil || hash != bd.Sha256 {
+		t.Fatalf("got url: %v, hash: %v", bd.Url, bd.Sha256)
+	}
+
+	for _, c := range clients {
+		bds, err := c.list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bds) != 1 || bds[0].Sha256 != bd.Sha256 {
+			t.Fatalf("server: %v, got blobs: %v", c.server, bds)
+		}
+	}
+
+	if err := clients[1].delete(bd.Sha256); err != nil {
+		t.Fatal(err)
+	}
+	if bds, _ := clients[1].list(); len(bds) != 0 {
+		t.Fatalf("got blobs after delete: %v", bds)
+	}
+}
+
+func TestBlossomFailingServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cc := confClass{}
+	cc.ConfData.Filename.Hsec = ".hsec"
+	sk, _, err := genHexKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.create(".hsec", sk); err != nil {
+		t.Fatal(err)
+	}
+
+	good := newBlossomStandIn()
+	defer good.srv.Close()
+	down := newBlossomStandIn()
+	down.srv.Close()
+
+	// one failing server fails the command, the others are still done
+	hash := strings.Repeat("0", 64)
+	if err := blossomList([]string{"nostk", "blossomList", down.srv.URL}, cc); err == nil {
+		t.Error("blossomList succeeded without a server")
+	}
+	if err := blossomDelete([]string{"nostk", "blossomDelete", hash, good.srv.URL, down.srv.URL}, cc); err == nil || !strings.Contains(err.Error(), down.srv.URL) {
+		t.Errorf("blossomDelete = %v", err)
+	}
+	if err := blossomMirror([]string{"nostk", "blossomMirror", good.srv.URL + "/" + hash, down.srv.URL}, cc); err == nil {
+		t.Error("blossomMirror succeeded without a server")
+	}
+}
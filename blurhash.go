@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strings"
+)
+
+/*
+BlurHash encoder {{{
+
+WHY WAS IT WRITTEN?
+NIP-92 imeta tags carry a blurhash of the image.
+The algorithm is small, so it is implemented here instead of adding
+a dependency.
+See: https://github.com/woltapp/blurhash/blob/master/Algorithm.md
+*/
+const (
+	blurhashCharacters  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+	blurhashMaxSamples  = 64 // pixels sampled per axis
+)
+
+func encodeBase83(value int, length int) string {
+	var builder strings.Builder
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		builder.WriteByte(blurhashCharacters[digit])
+	}
+	return builder.String()
+}
+func sRGBToLinear(value uint32) float64 {
+	v := float64(value>>8) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+func linearTosRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+func encodeBlurhash(img image.Image) (string, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 {
+		return "", errors.New("Empty image")
+	}
+	// Large images are sampled on a grid, which is plenty for a blur.
+	stepX := int(math.Max(1, math.Ceil(float64(width)/blurhashMaxSamples)))
+	stepY := int(math.Max(1, math.Ceil(float64(height)/blurhashMaxSamples)))
+
+	factors := make([][3]float64, 0, blurhashXComponents*blurhashYComponents)
+	for j := 0; j < blurhashYComponents; j++ {
+		for i := 0; i < blurhashXComponents; i++ {
+			var r, g, b float64
+			samples := 0
+			for y := 0; y < height; y += stepY {
+				for x := 0; x < width; x += stepX {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pr, pg, pb, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					r += basis * sRGBToLinear(pr)
+					g += basis * sRGBToLinear(pg)
+					b += basis * sRGBToLinear(pb)
+					samples++
+				}
+			}
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			scale := normalisation / float64(samples)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var builder strings.Builder
+	sizeFlag := (blurhashXComponents - 1) + (blurhashYComponents-1)*9
+	builder.WriteString(encodeBase83(sizeFlag, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if 0 < len(ac) {
+		actualMaximumValue := 0.0
+		for _, f := range ac {
+			actualMaximumValue = math.Max(actualMaximumValue, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMaximumValue := int(math.Max(0, math.Min(82, math.Floor(actualMaximumValue*166-0.5))))
+		maximumValue = float64(quantisedMaximumValue+1) / 166
+		builder.WriteString(encodeBase83(quantisedMaximumValue, 1))
+	} else {
+		builder.WriteString(encodeBase83(0, 1))
+	}
+
+	builder.WriteString(encodeBase83((linearTosRGB(dc[0])<<16)+(linearTosRGB(dc[1])<<8)+linearTosRGB(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		builder.WriteString(encodeBase83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return builder.String(), nil
+}
+
+// }}}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"io"
+	//"log"
+	"os"
+	"strings"
+)
+
+const (
+	dryRunFlag   = "--dry-run"
+	signOnlyFlag = "--sign-only"
+)
+
+var errPublishMode = errors.New("--dry-run and --sign-only are not supported by this subcommand")
+
+/* setPublishModeFlags {{{
+
+WHAT'S THIS?
+Takes --dry-run and --sign-only out of the arguments of any subcommand.
+	--dry-run:   print the unsigned event with every auto-added tag
+	--sign-only: print the signed event as one line of JSON
+Neither connects to the write relays.
+*/
+func (cc *confClass) setPublishModeFlags(args []string) ([]string, error) {
+	args, cc.dryRun = extractFlag(args, dryRunFlag)
+	args, cc.signOnly = extractFlag(args, signOnlyFlag)
+	if cc.dryRun && cc.signOnly {
+		return args, errors.New("--dry-run and --sign-only can not be used together")
+	}
+	return args, nil
+}
+
+// publishes reports whether events are sent to the relays in this run.
+func (cc confClass) publishes() bool {
+	return !cc.dryRun && !cc.signOnly
+}
+
+// }}}
+
+/* printEvent {{{
+
+WHAT'S THIS?
+Prints ev for --dry-run (indented for review) or --sign-only
+(one line, so that the output can be given to broadcast as JSONL).
+*/
+func printEvent(ev nostr.Event, indent bool) error {
+	var b []byte
+	var err error
+	if indent {
+		b, err = json.MarshalIndent(ev, "", "  ")
+	} else {
+		b, err = json.Marshal(ev)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// }}}
+
+/* readSignedEvents {{{
+
+WHAT'S THIS?
+Reads one or more signed events (a JSON object, or JSONL) and checks
+the id and the signature of each.
+*/
+func readSignedEvents(r io.Reader) ([]nostr.Event, error) {
+	dec := json.NewDecoder(r)
+	evs := []nostr.Event{}
+	var errs []error
+	for n := 1; ; n++ {
+		var ev nostr.Event
+		if err := dec.Decode(&ev); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Event %d: %v", n, err)
+		}
+		if err := checkSignedEvent(ev); err != nil {
+			errs = append(errs, fmt.Errorf("Event %d (%v): %w", n, ev.ID, err))
+			continue
+		}
+		evs = append(evs, ev)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(evs) < 1 {
+		return nil, errors.New("No events to broadcast")
+	}
+	return evs, nil
+}
+
+// checkSignedEvent checks that the id and the signature match ev.
+func checkSignedEvent(ev nostr.Event) error {
+	if !ev.CheckID() {
+		return errors.New("id does not match the event")
+	}
+	if ok, err := ev.CheckSignature(); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// }}}
+
+/*
+	broadcast {{{
+		[infomation for develop]
+		usage:
+			nostk broadcast <file|->
+		Sends pre-signed events, such as the output of --sign-only,
+		to the write relays. "-" reads standard input.
+*/
+func broadcast(args []string, cc confClass) error {
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	var src io.Reader
+	if args[2] == "-" {
+		s, err := readStdIn()
+		if err != nil {
+			return err
+		}
+		src = strings.NewReader(s)
+	} else {
+		f, err := os.Open(args[2])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+	evs, err := readSignedEvents(src)
+	if err != nil {
+		return err
+	}
+
+	var rl []string
+	if err := cc.getRelayList(&rl, writeFlag); err != nil {
+		return err
+	}
+	p := newPublisher(cc)
+	defer p.close()
+	var errs []error
+	for _, ev := range evs {
+		if _, err := deliverEvent(p, ev, rl, cc); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", ev.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
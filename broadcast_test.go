@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func signedEvent(t *testing.T, content string) nostr.Event {
+	ev := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: content, Tags: nostr.Tags{}}
+	if err := ev.Sign(nostr.GeneratePrivateKey()); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestReadSignedEvents(t *testing.T) {
+	a := signedEvent(t, "a")
+	b := signedEvent(t, "b")
+	la, _ := json.Marshal(a)
+	lb, _ := json.Marshal(b)
+	pretty, _ := json.MarshalIndent(a, "", "  ")
+
+	evs, err := readSignedEvents(strings.NewReader(string(la) + "\n" + string(lb) + "\n"))
+	if err != nil || len(evs) != 2 || evs[1].ID != b.ID {
+		t.Errorf("JSONL = %v, %v", evs, err)
+	}
+	if evs, err := readSignedEvents(strings.NewReader(string(pretty))); err != nil || len(evs) != 1 {
+		t.Errorf("single event = %v, %v", evs, err)
+	}
+
+	tampered := a
+	tampered.Content = "changed"
+	badSig := b
+	badSig.Sig = a.Sig
+	for name, ev := range map[string]nostr.Event{"tampered": tampered, "bad signature": badSig} {
+		l, _ := json.Marshal(ev)
+		if _, err := readSignedEvents(strings.NewReader(string(la) + "\n" + string(l))); err == nil {
+			t.Errorf("%v event was accepted", name)
+		}
+	}
+	for _, src := range []string{"", "{broken"} {
+		if _, err := readSignedEvents(strings.NewReader(src)); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
+
+func TestSetPublishModeFlags(t *testing.T) {
+	cc := confClass{}
+	args, err := cc.setPublishModeFlags([]string{"nostk", "pubMessage", "hello", "--dry-run"})
+	if err != nil || len(args) != 3 || !cc.dryRun || cc.publishes() {
+		t.Errorf("--dry-run = %v, %v, %+v", args, err, cc)
+	}
+	cc = confClass{}
+	if _, err := cc.setPublishModeFlags([]string{"nostk", "pubRaw", "--dry-run", "--sign-only"}); err == nil {
+		t.Error("--dry-run with --sign-only was accepted")
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	r := newTestRelay(t, &testRelay{})
+	cc := setupTestEnv(t, map[string]RwFlag{r.URL: {Write: true}})
+	cc.ConfData.Settings.MinSuccess = 1
+
+	var lines []string
+	for _, s := range []string{"one", "two"} {
+		b, _ := json.Marshal(signedEvent(t, s))
+		lines = append(lines, string(b))
+	}
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600)
+
+	if err := broadcast([]string{"nostk", "broadcast", path}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Received.Load(); n != 2 {
+		t.Errorf("relay received %d events", n)
+	}
+
+	// nothing is sent when --dry-run is given
+	cc.dryRun = true
+	if err := broadcast([]string{"nostk", "broadcast", path}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Received.Load(); n != 2 {
+		t.Errorf("relay received %d events with --dry-run", n)
+	}
+}
+
+func TestMkRawEventForDryRun(t *testing.T) {
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	ev, err := mkRawEvent(`{"kind": 1, "content": "dry #nostk", "tags": []}`, checkedTags, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID != "" || ev.Sig != "" || ev.PubKey == "" {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.Tags.FindWithValue("t", "nostk") == nil {
+		t.Errorf("hashtag was not added: %v", ev.Tags)
+	}
+}
@@ -63,6 +63,8 @@ func catEvent(args []string, cc confClass) error {
 		return err
 	}
 
+	mf := loadMuteFilter(cc)
+
 	var filters []nostr.Filter
 	filters = []nostr.Filter{{
 		IDs:   []string{eventId},
@@ -71,7 +73,7 @@ func catEvent(args []string, cc confClass) error {
 	}}
 
 	ctx := context.Background()
-	pool := nostr.NewSimplePool(ctx)
+	pool := cc.newPool(ctx, rs)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -79,13 +81,21 @@ func catEvent(args []string, cc confClass) error {
 	timer := time.NewTimer(wt)
 	defer timer.Stop()
 	go func() {
-		ch := pool.SubManyEose(ctx, rs, filters)
+		ch := cc.subManyEose(ctx, pool, rs, filters)
 		fmt.Println("{")
 		for event := range ch {
+			if mf.isMuted(event.Event) {
+				continue
+			}
 			switch event.Kind {
 			case 1:
 				buf := replacer.Replace(event.Content)
-				fmt.Printf("\"%v\": {\"date\": \"%v\", \"pubkey\": \"%v\", \"content\": \"%v\"},\n", event.ID, event.CreatedAt, event.PubKey, buf)
+				// an expired event is still displayed when asked by id, but flagged
+				expired := ""
+				if isExpired(event.Tags, nostr.Now()) {
+					expired = ", \"expired\": true"
+				}
+				fmt.Printf("\"%v\": {\"date\": \"%v\", \"pubkey\": \"%v\", \"content\": \"%v\"%v},\n", event.ID, event.CreatedAt, event.PubKey, buf, expired)
 			}
 		}
 		fmt.Println("}")
@@ -4,7 +4,7 @@ package main
 catHome {{{
 */
 func catHome(args []string, cc confClass) error {
-	if err := getNote(args, cc); err != nil {
+	if err := getNote(args, cc, CatHome); err != nil {
 		return err
 	}
 	return nil
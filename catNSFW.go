@@ -4,7 +4,7 @@ package main
 catNSFW {{{
 */
 func catNSFW(args []string, cc confClass) error {
-	if err := getNote(args, cc); err != nil {
+	if err := getNote(args, cc, CatNSFW); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,17 @@
+package main
+
+/*
+	catRelay {{{
+		[infomation for develop]
+		usage:
+			nostk catRelay <url> [number] [date] [--reactions] [--no-status]
+		Displays the global timeline of one relay.
+*/
+func catRelay(args []string, cc confClass) error {
+	if err := getNote(args, cc, CatRelay); err != nil {
+		return err
+	}
+	return nil
+}
+
+// }}}
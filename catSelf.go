@@ -4,7 +4,7 @@ package main
 catSelf
 */
 func catSelf(args []string, cc confClass) error {
-	if err := getNote(args, cc); err != nil {
+	if err := getNote(args, cc, CatSelf); err != nil {
 		return err
 	}
 	return nil
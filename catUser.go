@@ -0,0 +1,17 @@
+package main
+
+/*
+	catUser {{{
+		[infomation for develop]
+		usage:
+			nostk catUser <npub|nprofile|nip05> [number] [date] [--reactions] [--no-status]
+		Displays the notes of someone who is not in contacts.
+*/
+func catUser(args []string, cc confClass) error {
+	if err := getNote(args, cc, CatUser); err != nil {
+		return err
+	}
+	return nil
+}
+
+// }}}
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
 	//"log"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -54,14 +57,33 @@ func NewChkTblMap() ChkTblMap {
 	return ChkTblMap{
 		1:     {"content-warning", "client", "e", "emoji", "expiration", "p", "q", "r", "t"},
 		6:     {"e", "p"},
-		7:     {"e", "emoji", "k", "p"},
-		20:     {"title", "imeta", "L", "l", "location", "m", "p", "t", "x"},
+		7:     {"e", "emoji", "expiration", "k", "p"},
+		20:     {"title", "imeta", "L", "l", "location", "m", "p", "t", "x", "expiration"},
 		10000: {"e", "p", "t", "word"},
 		10001: {"e"},
+		10007: {"relay"},
+		10015: {"a", "t"},
+		10030: {"a", "emoji"},
+		10063: {"server"},
+		30023: {"d", "title", "summary", "image", "published_at", "t", "a", "e", "p", "emoji", "client"},
+		30024: {"d", "title", "summary", "image", "published_at", "t", "a", "e", "p", "emoji", "client"},
 		30030: {"d", "title", "emoji"},
 		30315: {"d", "emoji", "expiration", "r"},
 	}
 }
+
+// NewContentTagsTbl returns, for each kind, the tags that mkEvent
+// generates from content ("emoji" from short codes, "t" from hashtags).
+func NewContentTagsTbl() ChkTblMap {
+	return ChkTblMap{
+		1:     {"emoji", "t"},
+		7:     {"emoji"},
+		20:    {"t"},
+		30023: {"emoji"},
+		30024: {"emoji"},
+		30315: {"emoji"},
+	}
+}
 func (r ChkTblMap) sliceToMap(kind int) map[string]struct{} {
 	m := make(map[string]struct{})
 	for _, v := range r[kind] {
@@ -77,6 +99,10 @@ func (r ChkTblMap) contains(kind int, target string) bool {
 func checkTags(kind int, tgs nostr.Tags) error {
 	list := NewChkTblMap()
 	for _, tg := range tgs {
+		// NIP-13 proof of work is valid on every kind
+		if tg[indexTagName] == "nonce" {
+			continue
+		}
 		if result := list.contains(kind, tg[indexTagName]); result != true {
 			return errors.New("Inclusion of invalid tag in specified kind")
 		}
@@ -507,3 +533,199 @@ func hasPrefixInTags(tgs nostr.Tags) (string, bool) {
 }
 
 // }}}
+
+/* extractFlag {{{
+
+WHAT'S THIS?
+Removes a switch such as "--reactions" from the argument list and
+reports whether it was given. The remaining arguments keep their order,
+so the positional parsing of each subcommand works as before.
+*/
+func extractFlag(args []string, name string) ([]string, bool) {
+	ret := []string{}
+	found := false
+	for _, v := range args {
+		if v == name {
+			found = true
+			continue
+		}
+		ret = append(ret, v)
+	}
+	return ret, found
+}
+
+// }}}
+
+/* extractOption {{{
+
+WHAT'S THIS?
+Removes an option with a value ("--name value" or "--name=value") from
+the argument list and returns its value.
+When the option is given more than once, the last one wins.
+*/
+func extractOption(args []string, name string) ([]string, string, bool, error) {
+	ret, values, err := extractOptions(args, name)
+	if err != nil {
+		return args, "", false, err
+	}
+	if len(values) < 1 {
+		return ret, "", false, nil
+	}
+	return ret, values[len(values)-1], true, nil
+}
+
+// }}}
+
+/* extractOptions {{{
+
+WHAT'S THIS?
+Same as extractOption, but returns every value of an option that can
+be given more than once (ex. "--url a --url b").
+*/
+func extractOptions(args []string, name string) ([]string, []string, error) {
+	ret := []string{}
+	values := []string{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == name:
+			if len(args) <= i+1 {
+				return args, nil, fmt.Errorf("Option %v requires a value", name)
+			}
+			values = append(values, args[i+1])
+			i++
+		case strings.HasPrefix(args[i], name+"="):
+			values = append(values, strings.TrimPrefix(args[i], name+"="))
+		default:
+			ret = append(ret, args[i])
+		}
+	}
+	return ret, values, nil
+}
+
+// }}}
+
+/* toHexId {{{
+
+WHAT'S THIS?
+Converts an event id or a public key given as hex or bech32 to hex.
+prefs lists the bech32 prefixes accepted by the caller.
+*/
+func toHexId(s string, prefs ...string) (string, error) {
+	if is64HexString(s) {
+		return strings.ToLower(s), nil
+	}
+	decorder := Decorder{}
+	pref, hex, err := decorder.decord(s)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range prefs {
+		if v == pref {
+			return hex, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid id starting with %v", pref)
+}
+
+// }}}
+
+/* toEntityPointer {{{
+
+WHAT'S THIS?
+Converts an naddr or an "<kind>:<pubkey>:<d identifier>" string
+(the value of an "a" tag) to nostr.EntityPointer.
+*/
+func toEntityPointer(s string) (nostr.EntityPointer, error) {
+	if strings.HasPrefix(s, "naddr") {
+		pref, data, err := toHex(s)
+		if err != nil {
+			return nostr.EntityPointer{}, err
+		}
+		if ep, ok := data.(nostr.EntityPointer); ok && pref == "naddr" {
+			return ep, nil
+		}
+		return nostr.EntityPointer{}, fmt.Errorf("Invalid address %v", s)
+	}
+	spl := strings.SplitN(s, ":", 3)
+	if len(spl) == 3 && strings.HasPrefix(spl[1], "npub") {
+		pk, err := toHexId(spl[1], "npub")
+		if err != nil {
+			return nostr.EntityPointer{}, err
+		}
+		s = strings.Join([]string{spl[0], pk, spl[2]}, ":")
+	}
+	return nostr.EntityPointerFromTag(nostr.Tag{"a", s})
+}
+
+// }}}
+
+/* parseFutureTime {{{
+
+WHAT'S THIS?
+Converts the value of a time option (--expire, schedule) to a unix time.
+Accepts a duration from now ("90m", "1h"), a unix time or a date
+in the layout format ("2006/01/02 15:04" is read as local time).
+*/
+var futureTimeLayouts = []string{layout, "2006/01/02 15:04:05", "2006/01/02 15:04"}
+
+func parseFutureTime(s string) (nostr.Timestamp, error) {
+	var tp time.Time
+	if d, err := time.ParseDuration(s); err == nil {
+		tp = time.Now().Add(d)
+	} else if ut, err := strconv.ParseInt(s, 10, 64); err == nil {
+		tp = time.Unix(ut, 0)
+	} else {
+		for _, l := range futureTimeLayouts {
+			if tmp, err := time.ParseInLocation(l, s, time.Local); err == nil {
+				tp = tmp
+				break
+			}
+		}
+		if tp.IsZero() {
+			return 0, fmt.Errorf("Invalid time: %v", s)
+		}
+	}
+	if !tp.After(time.Now()) {
+		return 0, fmt.Errorf("Time must be in the future: %v", s)
+	}
+	return nostr.Timestamp(tp.Unix()), nil
+}
+
+// }}}
+
+/* parsePastTime {{{
+
+WHAT'S THIS?
+Converts the value of --since to a unix time.
+Accepts a duration before now ("24h"), a unix time or a date in the
+layouts of parseFutureTime.
+*/
+func parsePastTime(s string) (nostr.Timestamp, error) {
+	if d, err := time.ParseDuration(s); err == nil && 0 <= d {
+		return nostr.Timestamp(time.Now().Add(-d).Unix()), nil
+	}
+	if ut, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return nostr.Timestamp(ut), nil
+	}
+	for _, l := range futureTimeLayouts {
+		if tp, err := time.ParseInLocation(l, s, time.Local); err == nil {
+			return nostr.Timestamp(tp.Unix()), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid time: %v", s)
+}
+
+// }}}
+
+/* printJson {{{
+ */
+func printJson(v interface{}) error {
+	if data, err := json5.Marshal(v); err != nil {
+		return err
+	} else {
+		fmt.Printf("%v", string(data))
+	}
+	return nil
+}
+
+// }}}
@@ -248,3 +248,89 @@ func TestBuildJson(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractOption(t *testing.T) {
+	tests := []struct {
+		args  []string
+		rest  int
+		value string
+		found bool
+		err   bool
+	}{
+		{
+			args:  []string{"nostk", "catHome", "20"},
+			rest:  3,
+			value: "",
+			found: false,
+		},
+		{
+			args:  []string{"nostk", "pubMessage", "text", "--cw", "spoiler"},
+			rest:  3,
+			value: "spoiler",
+			found: true,
+		},
+		{
+			args:  []string{"nostk", "pubMessage", "--cw=spoiler", "text"},
+			rest:  3,
+			value: "spoiler",
+			found: true,
+		},
+		{
+			args: []string{"nostk", "pubMessage", "text", "--cw"},
+			err:  true,
+		},
+	}
+	for _, tc := range tests {
+		rest, value, found, err := extractOption(tc.args, "--cw")
+		if (err != nil) != tc.err {
+			t.Fatalf("args: %v, got error: %v", tc.args, err)
+		}
+		if err != nil {
+			continue
+		}
+		if len(rest) != tc.rest || value != tc.value || found != tc.found {
+			t.Fatalf("args: %v, got rest: %v, value: %v, found: %v", tc.args, rest, value, found)
+		}
+	}
+}
+
+func TestParseExpiration(t *testing.T) {
+	tests := []struct {
+		value string
+		err   bool
+	}{
+		{value: "1h", err: false},
+		{value: "4102444800", err: false},
+		{value: "2099/01/01 00:00:00 UTC", err: false},
+		{value: "2099/01/01 09:00", err: false},
+		{value: "-1h", err: true},
+		{value: "1000", err: true},
+		{value: "tomorrow", err: true},
+	}
+	now := nostr.Now()
+	for _, tc := range tests {
+		ts, err := parseFutureTime(tc.value)
+		if (err != nil) != tc.err {
+			t.Fatalf("value: %v, got error: %v", tc.value, err)
+		}
+		if err == nil && ts <= now {
+			t.Fatalf("value: %v, got past timestamp: %v", tc.value, ts)
+		}
+	}
+}
+
+func TestParsePastTime(t *testing.T) {
+	now := nostr.Now()
+	if ts, err := parsePastTime("24h"); err != nil || ts < now-86401 || now-86399 < ts {
+		t.Errorf("24h = %v, %v", ts, err)
+	}
+	if ts, err := parsePastTime("1700000000"); err != nil || ts != 1700000000 {
+		t.Errorf("unix time = %v, %v", ts, err)
+	}
+	if _, err := parsePastTime("2024/01/02 15:04"); err != nil {
+		t.Errorf("date: %v", err)
+	}
+	if _, err := parsePastTime("yesterday"); err == nil {
+		t.Error("yesterday was accepted")
+	}
+}
@@ -15,7 +15,12 @@ import (
 )
 
 type confClass struct {
-	ConfData Conf
+	ConfData   Conf
+	expiration nostr.Timestamp  // --expire, 0 for none
+	dryRun     bool             // --dry-run
+	signOnly   bool             // --sign-only
+	verifier   *EventVerifier   // counts received events for the fetch report
+	mutes      *MuteFilterCache // our mute list, read once per process
 }
 
 /*
@@ -25,20 +30,29 @@ type WrapConf struct {
 	Conf Conf `json:"conf"`
 }
 type Filename struct {
-	Contacts string `json:"contacts"`
-	Emoji    string `json:"emoji"`
-	Filters  string `json:"filters"`
-	Hpub     string `json:"hpub"`
-	Hsec     string `json:"hsec"`
-	Npub     string `json:"npub"`
-	Nsec     string `json:"nsec"`
-	Profile  string `json:"profile"`
-	Relays   string `json:"relays"`
+	Blossom    string `json:"blossom"`
+	Contacts   string `json:"contacts"`
+	Emoji      string `json:"emoji"`
+	EmojiCache string `json:"emojiCache"`
+	Filters    string `json:"filters"`
+	Hpub       string `json:"hpub"`
+	Hsec       string `json:"hsec"`
+	Npub       string `json:"npub"`
+	Nsec       string `json:"nsec"`
+	Profile    string `json:"profile"`
+	Relays     string `json:"relays"`
 }
 type Settings struct {
 	DefaultContentWarning       bool    `json:"defaultContentWarning"`
 	DefaultReadNo               int     `json:"defaultReadNo"`
 	MultiplierReadRelayWaitTime float64 `json:"multiplierReadRelayWaitTime"`
+	Uploader                    string  `json:"uploader"`
+	UploadCommand               string  `json:"uploadCommand"`
+	OutboxExpiry                string  `json:"outboxExpiry"`
+	MinSuccess                  int     `json:"minSuccess"`
+	ConnectTimeout              string  `json:"connectTimeout"`
+	OkTimeout                   string  `json:"okTimeout"`
+	PowDifficulty               int     `json:"powDifficulty"`
 }
 type Conf struct {
 	Filename Filename `json:"filename"`
@@ -67,8 +81,9 @@ type ProfileMetadata struct {
 for relays structure {{{
 */
 type RwFlag struct {
-	Read  bool `json:"read"`
-	Write bool `json:"write"`
+	Read  bool   `json:"read"`
+	Write bool   `json:"write"`
+	Auth  string `json:"auth,omitempty"`
 }
 
 // }}}
@@ -105,12 +120,21 @@ func (cc *confClass) existConfiguration() error {
       "relays" : "relays.json",
       "profile" : "profile.json",
       "emoji" : "customemoji.json",
+      "emojiCache" : "emojicache.json",
+      "blossom" : "blossom.json",
       "contacts" : "contacts.json"
     },
     "settings" : {
       "defaultReadNo" : 20,
       "multiplierReadRelayWaitTime" : 0.001,
-      "defaultContentWarning" : true
+      "defaultContentWarning" : true,
+      "uploader" : "",
+      "uploadCommand" : "",
+      "outboxExpiry" : "24h",
+      "minSuccess" : 1,
+      "connectTimeout" : "10s",
+      "okTimeout" : "10s",
+      "powDifficulty" : 0
     }
   }
 }
@@ -145,15 +169,48 @@ func (cc *confClass) loadConfiguration() error {
 		return err
 	}
 
+	// "minSuccess" : 0 is a valid setting, so a missing entry can only be
+	// told apart by setting the default before reading
+	ags.Conf.Settings.MinSuccess = defaultMinSuccess
 	if err := json5.Unmarshal([]byte(b), &ags); err != nil {
 		return err
 	}
 	cc.ConfData = ags.Conf
+	cc.setDefaultConfiguration()
 	return nil
 }
 
 // }}}
 
+/*
+setDefaultConfiguration {{{
+
+WHAT'S THIS?
+config.json made by an older nostk does not have the entries added
+later. Fill them with the default values.
+*/
+func (cc *confClass) setDefaultConfiguration() {
+	fn := &cc.ConfData.Filename
+	if fn.EmojiCache == "" {
+		fn.EmojiCache = "emojicache.json"
+	}
+	if fn.Blossom == "" {
+		fn.Blossom = "blossom.json"
+	}
+	st := &cc.ConfData.Settings
+	if st.OutboxExpiry == "" {
+		st.OutboxExpiry = defaultOutboxExpiry
+	}
+	if st.ConnectTimeout == "" {
+		st.ConnectTimeout = defaultConnectTimeout
+	}
+	if st.OkTimeout == "" {
+		st.OkTimeout = defaultOkTimeout
+	}
+}
+
+// }}}
+
 /*
 getConf {{{
 */
@@ -204,36 +261,49 @@ func (cc *confClass) getDir() (string, error) {
 getRelayList {{{
 */
 func (cc *confClass) getRelayList(rl *[]string, rwFlag int) error {
-	f, err := cc.openJSON5(cc.ConfData.Filename.Relays)
+	m, err := cc.loadRelays()
 	if err != nil {
 		return err
 	}
+
+	for i := range m {
+		if (m[i].Read == true && rwFlag == readFlag) ||
+			(m[i].Write == true && rwFlag == writeFlag) ||
+			(rwFlag == readWriteFlag) {
+			*rl = append(*rl, i)
+		}
+	}
+	return nil
+}
+
+// }}}
+
+/*
+loadRelays {{{
+*/
+func (cc *confClass) loadRelays() (map[string]RwFlag, error) {
+	f, err := cc.openJSON5(cc.ConfData.Filename.Relays)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
 	var data interface{}
 	dec := json5.NewDecoder(f)
 	err = dec.Decode(&data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	b, err := json5.Marshal(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	m := make(map[string]RwFlag)
 	if err := json5.Unmarshal([]byte(b), &m); err != nil {
-		return err
-	}
-
-	for i := range m {
-		if (m[i].Read == true && rwFlag == readFlag) ||
-			(m[i].Write == true && rwFlag == writeFlag) ||
-			(rwFlag == readWriteFlag) {
-			*rl = append(*rl, i)
-		}
+		return nil, err
 	}
-	return nil
+	return m, nil
 }
 
 // }}}
@@ -274,12 +344,20 @@ func (cc *confClass) getContactList(cl *[]string) error {
 
 /*
 setCustomEmoji {{{
+
+Short codes are resolved from customemoji.json and the emoji sets
+subscribed with the kind 10030 list. See resolveEmoji for the priority.
 */
 func (cc *confClass) setCustomEmoji(s string, tgs *nostr.Tags) error {
-	ts := make(map[string]string)
-	if err := cc.getCustomEmoji(&ts); err != nil {
+	local := make(map[string]string)
+	if err := cc.getCustomEmoji(&local); err != nil {
 		return err
 	}
+	cache, err := loadEmojiCache(*cc)
+	if err != nil {
+		return err
+	}
+	ts := resolveEmoji(local, cache)
 
 	const strexp = `:([^:]+):`
 	re := regexp.MustCompile(strexp)
@@ -334,6 +412,20 @@ func (cc *confClass) getMySelfPubkey(cl *[]string) error {
 
 // }}}
 
+/*
+getMySelfHexPubkey {{{
+*/
+func (cc *confClass) getMySelfHexPubkey() (string, error) {
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Println("Nothing key pair. Make key pair.")
+		return "", err
+	}
+	return nostr.GetPublicKey(sk)
+}
+
+// }}}
+
 /*
 create {{{
 */
@@ -395,6 +487,15 @@ func (cc *confClass) edit(fn string) error {
 		fmt.Printf("Not found %q. Use \"nostk init\"\n", fn)
 		return fmt.Errorf("Not found %q. Use \"nostk init\"\n", fn)
 	}
+	return runEditor(e, path)
+}
+
+// }}}
+
+/*
+runEditor {{{
+*/
+func runEditor(e string, path string) error {
 	c := exec.Command(e, path)
 	c.Stdin = os.Stdin
 	c.Stdout = os.Stdout
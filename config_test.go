@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigurationDefaults(t *testing.T) {
+	tests := []struct {
+		settings   string
+		minSuccess int
+	}{
+		{settings: `"defaultReadNo" : 20`, minSuccess: defaultMinSuccess},
+		{settings: `"minSuccess" : 0`, minSuccess: 0},
+		{settings: `"minSuccess" : 3`, minSuccess: 3},
+	}
+	for _, tc := range tests {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		os.MkdirAll(filepath.Join(home, secretDir), 0700)
+		conf := `{"conf" : {"filename" : {"relays" : "relays.json"}, "settings" : {` + tc.settings + `}}}`
+		os.WriteFile(filepath.Join(home, secretDir, confFile), []byte(conf), 0600)
+
+		cc := confClass{}
+		if err := cc.loadConfiguration(); err != nil {
+			t.Fatal(err)
+		}
+		if got := cc.ConfData.Settings.MinSuccess; got != tc.minSuccess {
+			t.Errorf("%v: minSuccess = %d, want %d", tc.settings, got, tc.minSuccess)
+		}
+		if cc.ConfData.Settings.OkTimeout != defaultOkTimeout {
+			t.Errorf("%v: okTimeout = %q", tc.settings, cc.ConfData.Settings.OkTimeout)
+		}
+	}
+}
@@ -20,7 +20,20 @@ func dispHelp() {
 			Edit your contact list.
 		editEmoji :
 			Edit custom emoji list.
+		editBlossom :
+			Edit Blossom media server list.
+		pubEmojiSet <d identifier> [title] :
+			Publish custom emoji list as an emoji set (kind 30030).
+		addEmojiSet <naddr|30030:pubkey:d> [--force] :
+			Add an emoji set to your emoji list (kind 10030).
+		removeEmojiSet <naddr|30030:pubkey:d> [--force] :
+			Remove an emoji set from your emoji list.
+			--force : start a new list when no read relay answers.
+		syncEmoji :
+			Refresh the local cache of emoji sets in your emoji list.
 
+		relayInfo [url] :
+			Display NIP-11 information of url or of every relay in relays.json.
 		pubRelays :
 			Publish relay list.
 		editProfile :
@@ -29,31 +42,161 @@ func dispHelp() {
 			Publish your profile.
 
 		pubMessage <text message> [reason for content warning]:
+		pubMessage [--cw reason] [--reply ID]:
 			Publish text message to relays.
+			Without text message, the message is read from piped standard input,
+			or composed in $EDITOR and published after confirmation.
 		pubMessageTo <text message> <pubkey>:
 			Publish text message to a some user.
+		pubPicture <file...> --title <title> [--url url...] [text]:
+			Publish a picture post (kind 20) with NIP-92 imeta tags.
+			Without --url, files are uploaded by "uploader" in config.json.
+		pubArticle <file.md> [--draft]:
+			Publish a long-form article (kind 30023) from a Markdown file.
+			title, summary, image, tags and d are read from the front matter.
+			published_at is kept from the published article; without an answer from
+			the read relays, set it in the front matter.
+			--draft: Publish as a draft (kind 30024).
+		draft new [name] [--kind n] [--cw reason] [--reply ID] :
+			Create a draft and open it in $EDITOR.
+		draft edit <name> :
+			Edit a draft in $EDITOR.
+		draft list :
+			List drafts.
+		draft show <name> :
+			Display a draft.
+		draft publish <name> :
+			Publish a draft and remove it.
+		draft delete <name> :
+			Remove a draft.
+		schedule <time> <text|draft name> :
+			Queue a message or a draft to be published at time.
+			time: duration from now (ex. 2h), unix time or "2006/01/02 15:04".
+		queue list :
+			List queued events and the relays that accepted sent ones.
+		queue cancel <id> :
+			Cancel a queued event.
+		queue run :
+			Publish queued events whose time has come. Run it from cron.
+		outbox status :
+			Display published events and the relays that failed.
+		outbox flush :
+			Retry failed relays with backoff until "outboxExpiry" in config.json.
+			Run it from cron.
 		pubRaw <raw data>:
 			Publish raw data in json format.
 			format:
 				See: https://spec.json5.org/
 				ex) "{\"kind\" : 1,\"content\" : \"test\",\"tags\":[[\"p\",\"c088_cut_off_05f9e6b5157b7d3416\"]]}"
+		export [--author npub] [--kinds 1,7,...]:
+			Write every event of yourself or the author to standard output as JSONL.
+		import <file> [--to url...]:
+			Publish an export archive to the write relays or --to relays.
+			Run it again to resume or to retry the relays that failed.
+		rebroadcast <id|note|nevent|naddr...> [--to url...]:
+			Copy events from every known relay to the write relays or --to relays.
+		rebroadcast --author <npub> [number] [--to url...]:
+			Copy the events of an author (all of them without number).
+		broadcast <file|->:
+			Check and publish pre-signed events (a JSON object or JSONL).
+			"-" reads standard input. ex) nostk pubMessage hello --sign-only > ev.jsonl
 
-		catHome [number]:
-			Display home timeline.
-		catNSFW [number]:
+		catHome [number] [--reactions] [--no-status] [--tags]:
+			Display home timeline. With --tags, notes of followed hashtags are merged.
+		catNSFW [number] [--reactions] [--no-status]:
 			Display home timeline include content warning contents.
-		catSelf [number]:
+		catSelf [number] [--reactions] [--no-status]:
 			Display your posts.
+		catUser <npub|nprofile|nip05> [number] [--reactions] [--no-status]:
+			Display the posts of a user without adding them to contacts.
+		catRelay <url> [number] [--reactions] [--no-status]:
+			Display the global timeline of a relay.
+			--reactions : attach likes, dislikes, reposts and emoji reactions to each note.
+			--no-status : do not attach the status (kind 30315) of each author.
+		catTag <tag...> [number] [--reactions] [--no-status] :
+			Display notes with any of the hashtags.
+		followTag <tag...> [--force] :
+			Follow hashtags (kind 10015 interests list).
+		unfollowTag <tag...> [--force] :
+			Unfollow hashtags.
+			--force : start a new list when no read relay answers.
+		catFollowedTags [npub] :
+			Display followed hashtags.
 		catEvent <ID> :
 			Display the event specified by Event ID.
+		search <query...> [--author npub] [--kind 1,...] [--since 24h] [--local] :
+			Search notes on NIP-50 relays, or in the local cache when they find nothing.
+		addSearchRelay <url> [--force] :
+			Add a relay to your search relay list (kind 10007).
+		removeSearchRelay <url> [--force] :
+			Remove a relay from your search relay list.
+			--force : start a new list when no read relay answers.
+		catSearchRelays :
+			Display the relays that search uses.
+		catArticles [npub|naddr] [number] [--draft] :
+			List long-form articles of yourself or npub.
+			With naddr, display the article with its content.
 
 		emojiReaction <ID> <pubkey> <kind> <reaction>:
 			React to specified events.
+		pubBlossom :
+			Publish Blossom media server list (kind 10063).
+		blossomUpload <file...> :
+			Upload files to the first Blossom server and mirror them to the others.
+		blossomList [server] :
+			List your blobs on Blossom servers.
+		blossomDelete <sha256|url> [server...] :
+			Delete a blob from Blossom servers.
+		blossomMirror <url> [server...] :
+			Mirror a blob to Blossom servers.
+
 		removeEvent <ID> <kind> [reason]:
 			Remove the event specified by Event ID or Note ID.
 
+		mute <p|e|t|word> <value> [--private] [--force]:
+			Add a pubkey, thread, hashtag or word to your mute list.
+			--private : encrypt the entry with NIP-44.
+		unmute <p|e|t|word> <value> [--force]:
+			Remove an entry from your mute list.
+			--force : start a new list when no read relay answers.
+		catMutes :
+			Display your mute list.
+
+		pin <ID> [--force] :
+			Pin the note specified by Event ID or Note ID to your profile.
+		unpin <ID> [--force] :
+			Unpin the note specified by Event ID or Note ID.
+			--force : start a new list when no read relay answers.
+		catPins [npub] :
+			Display pinned notes. Without npub, your pinned notes are displayed.
+
+		setStatus [general|music] <text> [--link url] [--expire duration]:
+			Set your status (kind 30315). ex) nostk setStatus "At the office" --expire 8h
+		clearStatus [general|music] :
+			Clear your status.
+
 		decord <bech32 string>
 			Decode bech32 string to hex string.
+
+	Options for every subcommand that publishes:
+		--min-success <N> :
+			Exit with a non-zero status when fewer than N relays accept the event.
+			Overrides "minSuccess" in config.json.
+		--pow <difficulty> :
+			Mine a NIP-13 proof of work before signing (pubRaw, pubMessage,
+			emojiReaction, draft publish). Overrides "powDifficulty" in config.json.
+		--expire <duration|time> :
+			Add a NIP-40 expiration (kind 1, 7, 20 and 30315).
+			ex) --expire 8h, --expire "2006/01/02 15:04" or unix time.
+		--dry-run :
+			Print the unsigned event with every auto-added tag instead of publishing.
+		--sign-only :
+			Print the signed event as one line of JSON instead of publishing.
+
+	Options for every subcommand that reads from relays:
+		--show-rejected :
+			Print events with a wrong id or signature and the number of
+			events received from each relay to standard error.
 `
 	fmt.Fprintf(os.Stderr, "%s\n", usageTxt)
 }
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	draftDir      = "drafts"
+	draftExt      = ".txt"
+	kindOption    = "--kind"
+	cwOption      = "--cw"
+	replyOption   = "--reply"
+	draftNameTmpl = "20060102-150405"
+)
+
+/*
+draft structure {{{
+
+A draft is a text file under ~/.nostk/drafts.
+The metadata is written in the header and the rest is the content.
+
+	---
+	kind: 1
+	content-warning:
+	reply: note1...
+	tag: ["r", "https://example.com"]
+	---
+	content
+*/
+type Draft struct {
+	Name           string     `json:"name"`
+	Kind           int        `json:"kind"`
+	ContentWarning string     `json:"content-warning,omitempty"`
+	Reply          string     `json:"reply,omitempty"`
+	Tags           nostr.Tags `json:"tags,omitempty"`
+	Content        string     `json:"content"`
+}
+
+// }}}
+
+/* parseDraft {{{
+ */
+func parseDraft(name string, src string) (Draft, error) {
+	d := Draft{Name: name, Kind: 1}
+	header, body, err := splitFrontMatter(src)
+	if err != nil {
+		return d, err
+	}
+	d.Content = strings.TrimRight(body, "\n")
+	for _, line := range header {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return d, fmt.Errorf("Invalid draft header line: %v", line)
+		}
+		v = strings.TrimSpace(v)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "kind":
+			if d.Kind, err = strconv.Atoi(v); err != nil {
+				return d, fmt.Errorf("Invalid kind: %v", v)
+			}
+		case "content-warning":
+			d.ContentWarning = unquote(v)
+		case "reply":
+			d.Reply = unquote(v)
+		case "tag":
+			var tg nostr.Tag
+			if err := json5.Unmarshal([]byte(v), &tg); err != nil || len(tg) < 1 {
+				return d, fmt.Errorf("Invalid tag: %v", v)
+			}
+			d.Tags = append(d.Tags, tg)
+		default:
+			return d, fmt.Errorf("Unknown draft header: %v", k)
+		}
+	}
+	return d, nil
+}
+
+// }}}
+
+/* Draft.format {{{
+ */
+func (r Draft) format() (string, error) {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("# Lines starting with \"#\" are ignored. Repeat \"tag\" for more tags.\n")
+	fmt.Fprintf(&b, "kind: %d\n", r.Kind)
+	fmt.Fprintf(&b, "content-warning: %v\n", r.ContentWarning)
+	fmt.Fprintf(&b, "reply: %v\n", r.Reply)
+	for _, tg := range r.Tags {
+		tmp, err := json5.Marshal(tg)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "tag: %s\n", tmp)
+	}
+	b.WriteString("---\n")
+	b.WriteString(r.Content)
+	if !strings.HasSuffix(r.Content, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// }}}
+
+/* Draft.rawArg {{{
+
+WHAT'S THIS?
+Builds the publishRaw data from the draft metadata.
+*/
+func (r Draft) rawArg(cc confClass) (RawArg, error) {
+	arg := RawArg{
+		Kind:    r.Kind,
+		Content: r.Content,
+		Tags:    nostr.Tags{},
+	}
+	if strings.TrimSpace(r.Content) == "" {
+		return arg, errors.New("Draft content is empty")
+	}
+	arg.Tags = append(arg.Tags, r.Tags...)
+	if 0 < len(r.ContentWarning) {
+		setContentWarning(r.ContentWarning, &arg.Tags)
+	}
+	if 0 < len(r.Reply) {
+		tgs, err := replyTags(r.Reply, cc)
+		if err != nil {
+			return arg, err
+		}
+		arg.Tags = append(arg.Tags, tgs...)
+	}
+	return arg, nil
+}
+
+// }}}
+
+/* replyTags {{{
+
+WHAT'S THIS?
+Builds NIP-10 marked "e" tags and the "p" tag for a reply to target.
+The target is read from relays to find the thread root.
+*/
+func replyTags(target string, cc confClass) (nostr.Tags, error) {
+	id, err := toHexId(target, "note", "nevent")
+	if err != nil {
+		return nil, err
+	}
+	ev, err := fetchLatestEvent(cc, nostr.Filter{IDs: []string{id}, Limit: singleReadNo})
+	if err != nil {
+		return nil, err
+	}
+	return mkReplyTags(ev), nil
+}
+
+func mkReplyTags(ev *nostr.Event) nostr.Tags {
+	root := ""
+	for _, tg := range ev.Tags {
+		if 4 <= len(tg) && tg[indexTagName] == "e" && tg[3] == "root" {
+			root = tg[1]
+			break
+		}
+	}
+	tgs := nostr.Tags{}
+	if root == "" {
+		tgs = append(tgs, nostr.Tag{"e", ev.ID, "", "root"})
+	} else {
+		tgs = append(tgs, nostr.Tag{"e", root, "", "root"})
+		tgs = append(tgs, nostr.Tag{"e", ev.ID, "", "reply"})
+	}
+	// notify the author and everyone in the thread
+	tgs = append(tgs, nostr.Tag{"p", ev.PubKey})
+	for _, tg := range ev.Tags {
+		if 2 <= len(tg) && tg[indexTagName] == "p" && indexOfTag(tgs, nostr.Tag{"p", tg[1]}) < 0 {
+			tgs = append(tgs, nostr.Tag{"p", tg[1]})
+		}
+	}
+	return tgs
+}
+
+// }}}
+
+/* draft file helpers {{{
+ */
+func draftFile(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("Invalid draft name %q", name)
+	}
+	return filepath.Join(draftDir, name+draftExt), nil
+}
+func (cc *confClass) draftPath(name string) (string, error) {
+	fn, err := draftFile(name)
+	if err != nil {
+		return "", err
+	}
+	d, err := cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Join(d, draftDir), 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(d, fn), nil
+}
+func (cc *confClass) loadDraft(name string) (Draft, error) {
+	path, err := cc.draftPath(name)
+	if err != nil {
+		return Draft{}, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Draft{}, fmt.Errorf("Not found draft %q", name)
+	}
+	return parseDraft(name, string(b))
+}
+
+// }}}
+
+/*
+	draft {{{
+		[infomation for develop]
+		usage:
+			nostk draft new [name] [--kind n] [--cw reason] [--reply id]
+			nostk draft edit <name>
+			nostk draft list
+			nostk draft show <name>
+			nostk draft publish <name>
+			nostk draft delete <name>
+*/
+func draft(args []string, cc confClass) error {
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+	switch args[2] {
+	case "new":
+		return newDraft(args, cc)
+	case "edit":
+		if len(args) != 4 {
+			return errors.New("Wrong number of parameters")
+		}
+		if _, err := cc.loadDraft(args[3]); err != nil {
+			return err
+		}
+		return editDraft(args[3], cc)
+	case "list":
+		return listDrafts(cc)
+	case "show":
+		if len(args) != 4 {
+			return errors.New("Wrong number of parameters")
+		}
+		d, err := cc.loadDraft(args[3])
+		if err != nil {
+			return err
+		}
+		return printJson(d)
+	case "publish":
+		if len(args) != 4 {
+			return errors.New("Wrong number of parameters")
+		}
+		return publishDraft(args[3], cc)
+	case "delete":
+		if len(args) != 4 {
+			return errors.New("Wrong number of parameters")
+		}
+		path, err := cc.draftPath(args[3])
+		if err != nil {
+			return err
+		}
+		return os.Remove(path)
+	}
+	return fmt.Errorf("Unknown draft command %v", args[2])
+}
+
+// }}}
+
+/* newDraft {{{
+ */
+func newDraft(args []string, cc confClass) error {
+	args, kind, hasKind, err := extractOption(args, kindOption)
+	if err != nil {
+		return err
+	}
+	args, cw, _, err := extractOption(args, cwOption)
+	if err != nil {
+		return err
+	}
+	args, reply, _, err := extractOption(args, replyOption)
+	if err != nil {
+		return err
+	}
+
+	d := Draft{Kind: 1, ContentWarning: cw, Reply: reply}
+	if hasKind {
+		if d.Kind, err = strconv.Atoi(kind); err != nil {
+			return fmt.Errorf("Invalid kind: %v", kind)
+		}
+	}
+	switch len(args) {
+	case 3:
+		d.Name = time.Now().Format(draftNameTmpl)
+	case 4:
+		d.Name = args[3]
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+
+	path, err := cc.draftPath(d.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("Draft %q already exists", d.Name)
+	}
+	s, err := d.format()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(s), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("draft: %v\n", d.Name)
+	return editDraft(d.Name, cc)
+}
+
+// }}}
+
+/* editDraft {{{
+ */
+func editDraft(name string, cc confClass) error {
+	fn, err := draftFile(name)
+	if err != nil {
+		return err
+	}
+	if err := cc.edit(fn); err != nil {
+		return err
+	}
+	_, err = cc.loadDraft(name)
+	return err
+}
+
+// }}}
+
+/* listDrafts {{{
+ */
+type DraftInfo struct {
+	Name           string `json:"name"`
+	Kind           int    `json:"kind"`
+	UpdatedAt      string `json:"updated_at"`
+	ContentWarning string `json:"content-warning,omitempty"`
+	Reply          string `json:"reply,omitempty"`
+	Head           string `json:"head"`
+	modTime        time.Time
+}
+
+func listDrafts(cc confClass) error {
+	d, err := cc.getDir()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(d, draftDir, "*"+draftExt))
+	if err != nil {
+		return err
+	}
+	infos := []DraftInfo{}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), draftExt)
+		st, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		dr, err := cc.loadDraft(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", name, err)
+			continue
+		}
+		head := strings.SplitN(dr.Content, "\n", 2)[0]
+		if r := []rune(head); 40 < len(r) {
+			head = string(r[:40]) + "..."
+		}
+		infos = append(infos, DraftInfo{
+			Name:           name,
+			Kind:           dr.Kind,
+			UpdatedAt:      st.ModTime().Format(layout),
+			ContentWarning: dr.ContentWarning,
+			Reply:          dr.Reply,
+			Head:           head,
+			modTime:        st.ModTime(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].modTime.After(infos[j].modTime)
+	})
+	return printJson(infos)
+}
+
+// }}}
+
+/* publishDraft {{{
+
+WHAT'S THIS?
+Publishes the draft through publishRaw, so it gets the same checks as
+other events. The draft is removed after publishing.
+*/
+func publishDraft(name string, cc confClass) error {
+	d, err := cc.loadDraft(name)
+	if err != nil {
+		return err
+	}
+	dataRawArg, err := d.rawArg(cc)
+	if err != nil {
+		return err
+	}
+	if err := publishRawArg(dataRawArg, checkedTags, cc); err != nil {
+		return err
+	}
+	if !cc.publishes() {
+		// keep the draft when it was only printed
+		return nil
+	}
+	path, err := cc.draftPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// }}}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"reflect"
+	"testing"
+)
+
+func TestDraftFormatAndParse(t *testing.T) {
+	d := Draft{
+		Name:           "test",
+		Kind:           1,
+		ContentWarning: "spoiler",
+		Reply:          "note1xyz",
+		Tags:           nostr.Tags{{"r", "https://example.com"}, {"t", "nostr"}},
+		Content:        "line 1\n---\nline 3",
+	}
+	s, err := d.format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseDraft("test", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("parseDraft(format()) = %+v, want %+v", got, d)
+	}
+
+	if _, err := parseDraft("bad", "---\nkind: one\n---\n"); err == nil {
+		t.Error("expected error for invalid kind")
+	}
+	if _, err := parseDraft("bad", "---\nunknown: x\n---\n"); err == nil {
+		t.Error("expected error for unknown header")
+	}
+	if got, err := parseDraft("plain", "no header\n"); err != nil || got.Kind != 1 || got.Content != "no header" {
+		t.Errorf("parseDraft without header = %+v, %v", got, err)
+	}
+}
+
+func TestMkReplyTags(t *testing.T) {
+	root := &nostr.Event{ID: "root", PubKey: "alice"}
+	if got, want := mkReplyTags(root), (nostr.Tags{{"e", "root", "", "root"}, {"p", "alice"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("reply to root = %v, want %v", got, want)
+	}
+
+	reply := &nostr.Event{ID: "child", PubKey: "bob", Tags: nostr.Tags{
+		{"e", "root", "", "root"},
+		{"p", "alice"},
+		{"p", "bob"},
+	}}
+	want := nostr.Tags{
+		{"e", "root", "", "root"},
+		{"e", "child", "", "reply"},
+		{"p", "bob"},
+		{"p", "alice"},
+	}
+	if got := mkReplyTags(reply); !reflect.DeepEqual(got, want) {
+		t.Errorf("reply in thread = %v, want %v", got, want)
+	}
+}
+
+func TestDraftFile(t *testing.T) {
+	for _, name := range []string{"", "../x", "a/b", ".hidden"} {
+		if _, err := draftFile(name); err == nil {
+			t.Errorf("draftFile(%q) should fail", name)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/yosuke-furukawa/json5/encoding/json5"
 	"regexp"
 	"unicode"
 	"unicode/utf8"
@@ -28,10 +27,6 @@ import (
 */
 func emojiReaction(args []string, cc confClass) error {
 	dataRawArg := RawArg{}
-	tmpArgs := []string{
-		"nostk",
-		"emojiReaction",
-	}
 
 	tgs := nostr.Tags{}
 	if len(args) < 6 {
@@ -102,12 +97,7 @@ func emojiReaction(args []string, cc confClass) error {
 	}
 	dataRawArg.Tags = tgs
 
-	if tmp, err := json5.Marshal(dataRawArg); err != nil {
-		return err
-	} else {
-		tmpArgs = append(tmpArgs, string(tmp))
-	}
-	if err := publishRaw(tmpArgs, cc); err != nil {
+	if err := publishRawArg(dataRawArg, checkedTags, cc); err != nil {
 		return err
 	}
 
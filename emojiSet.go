@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"io/fs"
+	//"log"
+	"regexp"
+	"sort"
+)
+
+/*
+emoji cache structure {{{
+
+Keeps the emoji of the kind 10030 user emoji list so that publishing
+does not need to connect to relays.
+Inline is the "emoji" tags of the list itself and Sets is the kind
+30030 sets referenced by its "a" tags in list order.
+*/
+type EmojiCacheSet struct {
+	Address string            `json:"address"`
+	Emoji   map[string]string `json:"emoji"`
+}
+type EmojiCache struct {
+	UpdatedAt int64             `json:"updated_at"`
+	Inline    map[string]string `json:"inline"`
+	Sets      []EmojiCacheSet   `json:"sets"`
+}
+
+// }}}
+
+/* resolveEmoji {{{
+
+WHAT'S THIS?
+Merges the local customemoji.json and the cached emoji.
+When the same short code exists in more than one place, the first of
+the following wins.
+	1. local customemoji.json
+	2. "emoji" tags of the kind 10030 list
+	3. kind 30030 sets in the order of the kind 10030 list
+*/
+func resolveEmoji(local map[string]string, cache EmojiCache) map[string]string {
+	ret := make(map[string]string)
+	sources := []map[string]string{local, cache.Inline}
+	for _, set := range cache.Sets {
+		sources = append(sources, set.Emoji)
+	}
+	for _, src := range sources {
+		for sc, url := range src {
+			if _, exists := ret[sc]; !exists {
+				ret[sc] = url
+			}
+		}
+	}
+	return ret
+}
+
+// }}}
+
+/* loadEmojiCache / saveEmojiCache {{{
+ */
+func loadEmojiCache(cc confClass) (EmojiCache, error) {
+	cache := EmojiCache{}
+	b, err := cc.load(cc.ConfData.Filename.EmojiCache)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return cache, err
+	}
+	if err := json.Unmarshal([]byte(b), &cache); err != nil {
+		return cache, err
+	}
+	return cache, nil
+}
+func saveEmojiCache(cache EmojiCache, cc confClass) error {
+	d, err := cc.getDir()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return err
+	}
+	return cc.save(d, cc.ConfData.Filename.EmojiCache, string(b))
+}
+
+// }}}
+
+/* syncEmojiCache {{{
+
+WHAT'S THIS?
+Fetches the kind 30030 sets referenced by the kind 10030 list tgs
+and rewrites the local cache.
+*/
+func syncEmojiCache(tgs nostr.Tags, cc confClass) error {
+	cache := EmojiCache{
+		UpdatedAt: int64(nostr.Now()),
+		Inline:    map[string]string{},
+		Sets:      []EmojiCacheSet{},
+	}
+	pointers := []nostr.EntityPointer{}
+	filters := nostr.Filters{}
+	for _, tg := range tgs {
+		if len(tg) < 2 {
+			continue
+		}
+		switch tg[indexTagName] {
+		case "emoji":
+			if 2 < len(tg) {
+				cache.Inline[tg[1]] = tg[2]
+			}
+		case "a":
+			ep, err := nostr.EntityPointerFromTag(tg)
+			if err != nil || ep.Kind != nostr.KindEmojiSets {
+				continue
+			}
+			pointers = append(pointers, ep)
+			filters = append(filters, ep.AsFilter())
+		}
+	}
+
+	if 0 < len(filters) {
+		evs, err := fetchEvents(cc, filters, len(filters))
+		if err != nil {
+			return err
+		}
+		for _, ep := range pointers {
+			var latest *nostr.Event
+			for _, ev := range evs {
+				if ep.MatchesEvent(*ev) && (latest == nil || latest.CreatedAt < ev.CreatedAt) {
+					latest = ev
+				}
+			}
+			if latest == nil {
+				fmt.Printf("Not found emoji set %v\n", ep.AsTagReference())
+				continue
+			}
+			set := EmojiCacheSet{
+				Address: ep.AsTagReference(),
+				Emoji:   map[string]string{},
+			}
+			for _, tg := range latest.Tags {
+				if 2 < len(tg) && tg[indexTagName] == "emoji" {
+					set.Emoji[tg[1]] = tg[2]
+				}
+			}
+			cache.Sets = append(cache.Sets, set)
+		}
+	}
+	return saveEmojiCache(cache, cc)
+}
+
+// }}}
+
+/* loadEmojiList {{{
+
+WHAT'S THIS?
+Reads our newest kind 10030 user emoji list with all its tags.
+An empty list is returned when nothing has been published yet; see
+fetchReplaceableList for force.
+*/
+func loadEmojiList(cc confClass, force bool) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return tgs, err
+	}
+	ev, err := fetchReplaceableList(cc, nostr.KindEmojiList, pk, force)
+	if err != nil || ev == nil {
+		return tgs, err
+	}
+	for _, tg := range ev.Tags {
+		if 0 < len(tg) {
+			tgs = append(tgs, tg)
+		}
+	}
+	return tgs, nil
+}
+
+// }}}
+
+/* publishEmojiList {{{
+ */
+func publishEmojiList(tgs nostr.Tags, cc confClass) error {
+	dataRawArg := RawArg{
+		Kind:    nostr.KindEmojiList,
+		Content: "",
+		Tags:    tgs,
+	}
+	return publishRawArg(dataRawArg, keptTags, cc)
+}
+
+// }}}
+
+/*
+	pubEmojiSet {{{
+		[infomation for develop]
+		usage:
+			nostk pubEmojiSet <d identifier> [title]
+		kind: 30030
+		content: ""
+		tags [
+			"d": identifier
+			"title": title (optional)
+			"emoji": short_code, image_url (customemoji.json)
+		]
+*/
+func pubEmojiSet(args []string, cc confClass) error {
+	if len(args) < 3 || 4 < len(args) {
+		return errors.New("Wrong number of parameters")
+	}
+	identifier := args[2]
+
+	ts := make(map[string]string)
+	if err := cc.getCustomEmoji(&ts); err != nil {
+		return err
+	}
+	scs := []string{}
+	re := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	for sc := range ts {
+		if re.MatchString(sc) == false {
+			fmt.Printf("Skip invalid short code %q\n", sc)
+			continue
+		}
+		scs = append(scs, sc)
+	}
+	if len(scs) < 1 {
+		return errors.New("No custom emoji to publish. Use \"nostk editEmoji\".")
+	}
+	sort.Strings(scs)
+
+	tgs := nostr.Tags{{"d", identifier}}
+	if len(args) == 4 {
+		tgs = append(tgs, nostr.Tag{"title", args[3]})
+	}
+	for _, sc := range scs {
+		tgs = append(tgs, nostr.Tag{"emoji", sc, ts[sc]})
+	}
+
+	dataRawArg := RawArg{
+		Kind:    nostr.KindEmojiSets,
+		Content: "",
+		Tags:    tgs,
+	}
+	if err := publishRawArg(dataRawArg, checkedTags, cc); err != nil {
+		return err
+	}
+
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+	if naddr, err := nip19.EncodeEntity(pk, nostr.KindEmojiSets, identifier, nil); err == nil {
+		fmt.Printf("emoji set address: %v\n", naddr)
+	}
+	return nil
+}
+
+// }}}
+
+/*
+	addEmojiSet {{{
+		[infomation for develop]
+		usage:
+			nostk addEmojiSet <naddr|30030:pubkey:d> [--force]
+		kind: 10030
+		content: ""
+		tags [
+			"a": 30030:pubkey:d
+			"emoji": short_code, image_url (kept as is)
+		]
+*/
+func addEmojiSet(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	ep, err := toEntityPointer(args[2])
+	if err != nil {
+		return err
+	}
+	if ep.Kind != nostr.KindEmojiSets {
+		return fmt.Errorf("%v is not an emoji set", args[2])
+	}
+
+	tgs, err := loadEmojiList(cc, force)
+	if err != nil {
+		return err
+	}
+	tg := nostr.Tag{"a", ep.AsTagReference()}
+	if 0 <= indexOfTag(tgs, tg) {
+		return fmt.Errorf("%v is already in your emoji list", args[2])
+	}
+	tgs = append(tgs, tg)
+	if err := publishEmojiList(tgs, cc); err != nil {
+		return err
+	}
+	return syncEmojiCache(tgs, cc)
+}
+
+// }}}
+
+/*
+	removeEmojiSet {{{
+		[infomation for develop]
+		usage:
+			nostk removeEmojiSet <naddr|30030:pubkey:d> [--force]
+*/
+func removeEmojiSet(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	ep, err := toEntityPointer(args[2])
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadEmojiList(cc, force)
+	if err != nil {
+		return err
+	}
+	tgs, removed := removeTag(tgs, nostr.Tag{"a", ep.AsTagReference()})
+	if removed == false {
+		return fmt.Errorf("%v is not in your emoji list", args[2])
+	}
+	if err := publishEmojiList(tgs, cc); err != nil {
+		return err
+	}
+	return syncEmojiCache(tgs, cc)
+}
+
+// }}}
+
+/* syncEmoji {{{
+ */
+func syncEmoji(args []string, cc confClass) error {
+	// without an answer the cache is kept rather than emptied
+	tgs, err := loadEmojiList(cc, false)
+	if err != nil {
+		return err
+	}
+	return syncEmojiCache(tgs, cc)
+}
+
+// }}}
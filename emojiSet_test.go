@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveEmoji(t *testing.T) {
+	local := map[string]string{"smile": "https://local/smile.webp"}
+	cache := EmojiCache{
+		Inline: map[string]string{
+			"smile": "https://inline/smile.webp",
+			"wave":  "https://inline/wave.webp",
+		},
+		Sets: []EmojiCacheSet{
+			{
+				Address: "30030:c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416:first",
+				Emoji: map[string]string{
+					"wave": "https://first/wave.webp",
+					"cat":  "https://first/cat.webp",
+				},
+			},
+			{
+				Address: "30030:c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416:second",
+				Emoji: map[string]string{
+					"cat": "https://second/cat.webp",
+					"dog": "https://second/dog.webp",
+				},
+			},
+		},
+	}
+	tests := map[string]string{
+		"smile": "https://local/smile.webp",
+		"wave":  "https://inline/wave.webp",
+		"cat":   "https://first/cat.webp",
+		"dog":   "https://second/dog.webp",
+	}
+	ret := resolveEmoji(local, cache)
+	for sc, url := range tests {
+		if ret[sc] != url {
+			t.Fatalf("short code: %v, got: %v, Want: %v", sc, ret[sc], url)
+		}
+	}
+}
+
+func TestToEntityPointer(t *testing.T) {
+	tests := []struct {
+		addr string
+		err  bool
+	}{
+		{addr: "30030:c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416:emoji", err: false},
+		{addr: "30030:npub1czyqt7dafpysfye9w3agpp4rcrsxnt0tr8v0t8kyu66327maxstq5ckh7u:emoji", err: false},
+		{addr: "30030:emoji", err: true},
+	}
+	for _, tc := range tests {
+		ep, err := toEntityPointer(tc.addr)
+		if (err != nil) != tc.err {
+			t.Fatalf("addr: %v, got error: %v", tc.addr, err)
+		}
+		if err == nil && ep.PublicKey != "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416" {
+			t.Fatalf("addr: %v, got pubkey: %v", tc.addr, ep.PublicKey)
+		}
+	}
+}
+
+func TestAddEmojiSetKeepsTags(t *testing.T) {
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc := setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+	set := "30030:" + strings.Repeat("a", 64) + ":cats"
+	if err := addEmojiSet([]string{"nostk", "addEmojiSet", set}, cc); err == nil {
+		t.Error("added without reading the list")
+	}
+
+	sk, _ := cc.load(cc.ConfData.Filename.Hsec)
+	list := nostr.Event{
+		Kind:      nostr.KindEmojiList,
+		CreatedAt: nostr.Now() - 10,
+		Tags:      nostr.Tags{{"emoji", "wave", "https://example.com/wave.png"}, {"client", "other"}},
+	}
+	list.Sign(sk)
+	r := newTestRelay(t, &testRelay{Stored: []nostr.Event{list}})
+	cc = setupTestEnv(t, map[string]RwFlag{r.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.MinSuccess = 1
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, ".hsec"), []byte(sk), 0600)
+
+	if err := addEmojiSet([]string{"nostk", "addEmojiSet", set}, cc); err != nil {
+		t.Fatal(err)
+	}
+	tgs, err := loadEmojiList(cc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 3 || tgs[1][indexTagName] != "client" || tgs[2][1] != set {
+		t.Errorf("emoji list = %v", tgs)
+	}
+}
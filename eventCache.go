@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	eventCacheFile = "events.jsonl"
+	eventCacheMax  = 10000
+)
+
+/* event cache {{{
+
+WHAT'S THIS?
+The notes read by timelines and searches are kept in
+~/.nostk/events.jsonl, one event per line, so that they can be
+searched without relays. Only the newest eventCacheMax are kept.
+*/
+func (cc *confClass) eventCachePath() (string, error) {
+	d, err := cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, eventCacheFile), nil
+}
+
+// loadEventCache reads the cache, newest first. A missing file is empty.
+func (cc *confClass) loadEventCache() ([]nostr.Event, error) {
+	path, err := cc.eventCachePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []nostr.Event{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	evs := []nostr.Event{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var ev nostr.Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			// a line cut by a crash is skipped, the rest is still usable
+			continue
+		}
+		evs = append(evs, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].CreatedAt > evs[j].CreatedAt
+	})
+	return evs, nil
+}
+
+/*
+cacheEvents adds evs to the cache. Events already cached are skipped
+and the oldest are dropped beyond eventCacheMax.
+*/
+func (cc *confClass) cacheEvents(evs []nostr.Event) error {
+	if len(evs) < 1 {
+		return nil
+	}
+	cached, err := cc.loadEventCache()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]struct{})
+	for _, ev := range cached {
+		seen[ev.ID] = struct{}{}
+	}
+	added := false
+	for _, ev := range evs {
+		if _, ok := seen[ev.ID]; ok {
+			continue
+		}
+		seen[ev.ID] = struct{}{}
+		cached = append(cached, ev)
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	sort.SliceStable(cached, func(i, j int) bool {
+		return cached[i].CreatedAt > cached[j].CreatedAt
+	})
+	if eventCacheMax < len(cached) {
+		cached = cached[:eventCacheMax]
+	}
+
+	path, err := cc.eventCachePath()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, ev := range cached {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cacheNotes is cacheEvents for timelines; a failure is only reported.
+func (cc *confClass) cacheNotes(notes []Recieve) {
+	evs := []nostr.Event{}
+	for _, n := range notes {
+		evs = append(evs, n.Event)
+	}
+	if err := cc.cacheEvents(evs); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update %v: %v\n", eventCacheFile, err)
+	}
+}
+
+// }}}
+
+/* searchEventCache {{{
+
+WHAT'S THIS?
+Returns the cached events matching filter whose content contains
+every word of query, ignoring case. Newest first, up to filter.Limit.
+*/
+func (cc *confClass) searchEventCache(query string, filter nostr.Filter) ([]nostr.Event, error) {
+	cached, err := cc.loadEventCache()
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(query))
+	ret := []nostr.Event{}
+	for i := range cached {
+		if !filter.Matches(&cached[i]) || !containsWords(cached[i].Content, words) {
+			continue
+		}
+		ret = append(ret, cached[i])
+		if 0 < filter.Limit && filter.Limit <= len(ret) {
+			break
+		}
+	}
+	return ret, nil
+}
+
+// containsWords reports whether s contains every word of words (lower case).
+func containsWords(s string, words []string) bool {
+	s = strings.ToLower(s)
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func cachedEvent(id string, kind int, pk string, at nostr.Timestamp, content string) nostr.Event {
+	return nostr.Event{ID: id, Kind: kind, PubKey: pk, CreatedAt: at, Content: content}
+}
+
+func TestCacheEvents(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cc := confClass{}
+
+	if evs, err := cc.loadEventCache(); err != nil || len(evs) != 0 {
+		t.Fatalf("empty cache = %v, %v", evs, err)
+	}
+	if err := cc.cacheEvents([]nostr.Event{
+		cachedEvent("a", 1, "alice", 100, "first"),
+		cachedEvent("b", 1, "alice", 300, "third"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a line cut by a crash must not lose the rest
+	path := filepath.Join(home, secretDir, eventCacheFile)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString("{\"id\":\"broken\n")
+	f.Close()
+	if err := cc.cacheEvents([]nostr.Event{
+		cachedEvent("b", 1, "alice", 300, "third"),
+		cachedEvent("c", 1, "bob", 200, "second"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	evs, err := cc.loadEventCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, ev := range evs {
+		got += ev.ID
+	}
+	if got != "bca" {
+		t.Errorf("cache = %q, want newest first without duplicates", got)
+	}
+}
+
+func TestSearchEventCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cc := confClass{}
+	cc.cacheEvents([]nostr.Event{
+		cachedEvent("a", 1, "alice", 100, "Nostr search is fun"),
+		cachedEvent("b", 1, "bob", 200, "searching nostr relays"),
+		cachedEvent("c", 30023, "alice", 300, "nostr SEARCH article"),
+		cachedEvent("d", 1, "alice", 400, "nothing here"),
+	})
+
+	since := nostr.Timestamp(150)
+	tests := []struct {
+		name   string
+		query  string
+		filter nostr.Filter
+		want   string
+	}{
+		{"words in any order and case", "search NOSTR", nostr.Filter{}, "cba"},
+		{"kind", "nostr search", nostr.Filter{Kinds: []int{1}}, "ba"},
+		{"author", "nostr", nostr.Filter{Authors: []string{"alice"}}, "ca"},
+		{"since", "nostr", nostr.Filter{Since: &since}, "cb"},
+		{"limit", "nostr", nostr.Filter{Limit: 1}, "c"},
+		{"no match", "bitcoin", nostr.Filter{}, ""},
+	}
+	for _, tc := range tests {
+		evs, err := cc.searchEventCache(tc.query, tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, ev := range evs {
+			got += ev.ID
+		}
+		if got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
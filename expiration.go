@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"strconv"
+)
+
+/* setExpireOption {{{
+
+WHAT'S THIS?
+Takes --expire out of the arguments of any subcommand.
+Events published by the subcommand get a NIP-40 expiration tag.
+*/
+func (cc *confClass) setExpireOption(args []string) ([]string, error) {
+	args, v, ok, err := extractOption(args, expireOption)
+	if err != nil || !ok {
+		return args, err
+	}
+	ts, err := parseFutureTime(v)
+	if err != nil {
+		return args, err
+	}
+	cc.expiration = ts
+	return args, nil
+}
+
+// }}}
+
+/* addExpiration {{{
+
+WHAT'S THIS?
+Adds the expiration of --expire to tgs unless tgs already has one.
+Returns an error when kind does not take an expiration tag.
+*/
+func (cc confClass) addExpiration(kind int, tgs *nostr.Tags) error {
+	if cc.expiration == 0 {
+		return nil
+	}
+	if !NewChkTblMap().contains(kind, "expiration") {
+		return fmt.Errorf("--expire is not supported for kind %d", kind)
+	}
+	if tgs.Find("expiration") == nil {
+		*tgs = append(*tgs, nostr.Tag{"expiration", strconv.FormatInt(int64(cc.expiration), 10)})
+	}
+	return nil
+}
+
+// }}}
+
+/* getExpiration {{{
+
+WHAT'S THIS?
+Returns the NIP-40 expiration of tgs, or 0 when there is none.
+*/
+func getExpiration(tgs nostr.Tags) nostr.Timestamp {
+	tag := tgs.Find("expiration")
+	if tag == nil {
+		return 0
+	}
+	ts, err := strconv.ParseInt(tag[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return nostr.Timestamp(ts)
+}
+
+// isExpired reports whether the expiration of tgs has passed at now.
+func isExpired(tgs nostr.Tags, now nostr.Timestamp) bool {
+	exp := getExpiration(tgs)
+	return exp != 0 && exp <= now
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsExpired(t *testing.T) {
+	tests := []struct {
+		tgs  nostr.Tags
+		want bool
+	}{
+		{nostr.Tags{}, false},
+		{nostr.Tags{{"expiration", "100"}}, true},
+		{nostr.Tags{{"expiration", "200"}}, true},
+		{nostr.Tags{{"expiration", "201"}}, false},
+		{nostr.Tags{{"expiration", "soon"}}, false},
+		{nostr.Tags{{"expiration"}}, false},
+	}
+	for i, tt := range tests {
+		if got := isExpired(tt.tgs, 200); got != tt.want {
+			t.Errorf("%d: isExpired(%v) = %v", i, tt.tgs, got)
+		}
+	}
+}
+
+func TestAddExpiration(t *testing.T) {
+	cc := confClass{}
+	args, err := cc.setExpireOption([]string{"nostk", "pubMessage", "hello", "--expire", "1h"})
+	if err != nil || len(args) != 3 {
+		t.Fatalf("setExpireOption = %v, %v", args, err)
+	}
+	if d := cc.expiration.Time().Sub(time.Now()); d < 59*time.Minute || time.Hour < d {
+		t.Errorf("expiration is %v from now", d)
+	}
+
+	tgs := nostr.Tags{{"t", "test"}}
+	if err := cc.addExpiration(1, &tgs); err != nil || getExpiration(tgs) != cc.expiration {
+		t.Errorf("addExpiration = %v, %v", tgs, err)
+	}
+	tgs = nostr.Tags{{"expiration", "4000000000"}}
+	if err := cc.addExpiration(1, &tgs); err != nil || len(tgs) != 1 || getExpiration(tgs) != 4000000000 {
+		t.Errorf("expiration given in tags was replaced: %v, %v", tgs, err)
+	}
+	if err := cc.addExpiration(10000, &nostr.Tags{}); err == nil {
+		t.Error("expiration was added to a mute list")
+	}
+
+	if _, err := cc.setExpireOption([]string{"nostk", "pubMessage", "hello", "--expire", "-1h"}); err == nil {
+		t.Error("past expiration was accepted")
+	}
+}
+
+func TestFlushOutboxDropsExpired(t *testing.T) {
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	ev := nostr.Event{Kind: 1, CreatedAt: nostr.Now() - 100, Content: "gone", Tags: nostr.Tags{{"expiration", "1"}}}
+	ev.Sign(nostr.GeneratePrivateKey())
+	e := OutboxEntry{
+		Event:     ev,
+		ExpiresAt: nostr.Now() + 3600,
+		Relays:    []OutboxRelay{{Relay: "ws://127.0.0.1:1", Attempts: 1}},
+	}
+	if err := cc.saveOutboxEntry(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := flushOutbox(cc); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := cc.outboxPath(ev.ID)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expired event is kept in the outbox: %v", err)
+	}
+}
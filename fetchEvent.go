@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	forceFlag = "--force"
+)
+
+var errEventNotFound = errors.New("Event not found")
+
+/* readWaitTime {{{
+
+WHAT'S THIS?
+Returns how long to wait for relays when reading num events.
+*/
+func readWaitTime(cc confClass, num int) time.Duration {
+	c := cc.getConf()
+	return time.Duration(int64(math.Ceil(float64(num)*c.Settings.MultiplierReadRelayWaitTime))) * time.Second
+}
+
+// }}}
+
+/* fetchRelayEvents {{{
+
+WHAT'S THIS?
+Reads events matching filters from the read relays until every relay
+sends EOSE or the wait time runs out.
+The relay of each event is kept for nevent encoding.
+Events with a wrong id or signature, and events whose NIP-40
+expiration has passed are dropped.
+*/
+func fetchRelayEvents(cc confClass, filters nostr.Filters, num int) ([]nostr.RelayEvent, error) {
+	var rs []string
+	if err := cc.getRelayList(&rs, readFlag); err != nil {
+		return nil, err
+	}
+	return fetchRelayEventsFrom(cc, rs, filters, num), nil
+}
+
+// fetchRelayEventsFrom is fetchRelayEvents from the relays of rs.
+func fetchRelayEventsFrom(cc confClass, rs []string, filters nostr.Filters, num int) []nostr.RelayEvent {
+	evs := []nostr.RelayEvent{}
+	for _, ev := range fetchRelayEventsWithExpired(cc, rs, filters, num) {
+		if isExpired(ev.Tags, nostr.Now()) {
+			continue
+		}
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+// fetchRelayEventsWithExpired is fetchRelayEventsFrom that keeps expired
+// events, so that paging sees every event a relay sent.
+func fetchRelayEventsWithExpired(cc confClass, rs []string, filters nostr.Filters, num int) []nostr.RelayEvent {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+	pool := cc.newPool(pctx, rs)
+	ctx, cancel := context.WithTimeout(pctx, readWaitTime(cc, num))
+	defer cancel()
+
+	evs := []nostr.RelayEvent{}
+	for ev := range cc.subManyEose(ctx, pool, rs, filters) {
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+// }}}
+
+/* fetchAllEvents {{{
+
+WHAT'S THIS?
+Reads every event matching filter from rs, paging backwards with
+"until" by page events. Each relay is paged with its own "until" until
+it sends no event older than that, so that relays holding different
+time ranges, or capping the limit below page, are read to the end.
+Stops after max events when max is not 0. page is called with the
+events of each page, which may be empty, and can stop the paging by
+returning false. Events are deduplicated across relays and pages.
+*/
+func fetchAllEvents(cc confClass, rs []string, filter nostr.Filter, pageSize, max int, page func([]nostr.RelayEvent) bool) {
+	seen := make(map[string]struct{})
+	total := 0
+	untils := make(map[string]*nostr.Timestamp)
+	for _, url := range rs {
+		untils[url] = filter.Until
+	}
+	for 0 < len(rs) {
+		pages := make([][]nostr.RelayEvent, len(rs))
+		var wg sync.WaitGroup
+		for i, url := range rs {
+			wg.Add(1)
+			go func(i int, url string) {
+				defer wg.Done()
+				f := filter
+				f.Until = untils[url]
+				f.Limit = pageSize
+				pages[i] = fetchRelayEventsWithExpired(cc, []string{url}, nostr.Filters{f}, pageSize)
+			}(i, url)
+		}
+		wg.Wait()
+
+		news := []nostr.RelayEvent{}
+		next := []string{}
+		for i, url := range rs {
+			oldest := nostr.Timestamp(0)
+			for _, ev := range pages[i] {
+				if oldest == 0 || ev.CreatedAt < oldest {
+					oldest = ev.CreatedAt
+				}
+				if isExpired(ev.Tags, nostr.Now()) {
+					continue
+				}
+				if _, ok := seen[ev.ID]; ok {
+					continue
+				}
+				if 0 < max && max <= total+len(news) {
+					continue
+				}
+				seen[ev.ID] = struct{}{}
+				news = append(news, ev)
+			}
+			// a page may be short of page by the limit of the relay, expired
+			// events or the wait time, so the relay is read to the end only
+			// when nothing older than "until" came
+			if u := untils[url]; oldest == 0 || (u != nil && *u <= oldest) {
+				continue
+			}
+			// events of the same second as the oldest may be cut by the limit,
+			// so the next page starts at that second again
+			untils[url] = &oldest
+			next = append(next, url)
+		}
+		total += len(news)
+		if !page(news) || (0 < max && max <= total) {
+			return
+		}
+		rs = next
+	}
+}
+
+// }}}
+
+/* fetchEvents {{{
+ */
+func fetchEvents(cc confClass, filters nostr.Filters, num int) ([]*nostr.Event, error) {
+	revs, err := fetchRelayEvents(cc, filters, num)
+	if err != nil {
+		return nil, err
+	}
+	evs := []*nostr.Event{}
+	for _, ev := range revs {
+		evs = append(evs, ev.Event)
+	}
+	return evs, nil
+}
+
+// }}}
+
+/* fetchLatestEvent {{{
+
+WHAT'S THIS?
+Reads the newest event matching filter.
+Used for replaceable events such as kind 10000 mute lists.
+*/
+func fetchLatestEvent(cc confClass, filter nostr.Filter) (*nostr.Event, error) {
+	evs, err := fetchEvents(cc, nostr.Filters{filter}, singleReadNo)
+	if err != nil {
+		return nil, err
+	}
+	var latest *nostr.Event
+	for _, ev := range evs {
+		if latest == nil || latest.CreatedAt < ev.CreatedAt {
+			latest = ev
+		}
+	}
+	if latest == nil {
+		return nil, errEventNotFound
+	}
+	return latest, nil
+}
+
+// }}}
+
+/* fetchReplaceableList {{{
+
+WHAT'S THIS?
+Reads the newest list of kind (10000, 10001, 10007, 10015, 10030 ...)
+of pk from the read relays, to be edited and published again.
+nil is returned only when a relay sent EOSE without the list, which
+means nothing has been published yet. When no relay answered, an empty
+list would replace the real one, so an error is returned unless force.
+*/
+func fetchReplaceableList(cc confClass, kind int, pk string, force bool) (*nostr.Event, error) {
+	var rs []string
+	if err := cc.getRelayList(&rs, readFlag); err != nil {
+		return nil, err
+	}
+	filter := nostr.Filter{Kinds: []int{kind}, Authors: []string{pk}, Limit: singleReadNo}
+	latest, answered := fetchLatestAnswered(cc, rs, filter)
+	if latest != nil {
+		return latest, nil
+	}
+	if answered < 1 && !force {
+		return nil, fmt.Errorf("No read relay answered for the kind %d list. Try again, or use %v to start a new list", kind, forceFlag)
+	}
+	return nil, nil
+}
+
+/*
+fetchLatestAnswered reads the newest event matching filter from rs and
+counts the relays that sent EOSE. Subscriptions closed with
+auth-required are authenticated and sent again once.
+*/
+func fetchLatestAnswered(cc confClass, rs []string, filter nostr.Filter) (*nostr.Event, int) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+	pool := cc.newPool(pctx, rs)
+	ra := newRelayAuth(cc)
+	// returns as soon as every relay sent EOSE, so the wait can be long
+	wait := readWaitTime(cc, singleReadNo) + parseDurationOr(cc.ConfData.Settings.ConnectTimeout, defaultConnectTimeout)
+	ctx, cancel := context.WithTimeout(pctx, wait)
+	defer cancel()
+
+	var mu sync.Mutex
+	var latest *nostr.Event
+	answered := 0
+	var wg sync.WaitGroup
+	for _, url := range rs {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relay, err := pool.EnsureRelay(url)
+			if err != nil {
+				return
+			}
+			authed := false
+		subscribe:
+			sub, err := relay.Subscribe(ctx, nostr.Filters{filter})
+			if err != nil {
+				return
+			}
+			defer sub.Unsub()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-sub.EndOfStoredEvents:
+					mu.Lock()
+					answered++
+					mu.Unlock()
+					return
+				case reason := <-sub.ClosedReason:
+					if strings.HasPrefix(reason, "auth-required:") && !authed {
+						if err := ra.authenticate(ctx, relay); err == nil {
+							authed = true
+							goto subscribe
+						}
+					}
+					return
+				case ev, more := <-sub.Events:
+					if !more {
+						return
+					}
+					if !cc.verifier.verify(nostr.RelayEvent{Event: ev, Relay: relay}, os.Stderr) {
+						continue
+					}
+					mu.Lock()
+					if latest == nil || latest.CreatedAt < ev.CreatedAt {
+						latest = ev
+					}
+					mu.Unlock()
+				}
+			}
+		}(nostr.NormalizeURL(url))
+	}
+	wg.Wait()
+	return latest, answered
+}
+
+// }}}
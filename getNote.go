@@ -5,27 +5,40 @@ import (
 	"errors"
 	"fmt"
 	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip05"
 	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/yosuke-furukawa/json5/encoding/json5"
 	//"log"
 	"math"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 /*
-const {{{
+note sources {{{
+
+The timelines served by getNote. Each decides the authors and the
+relays of the timeline in NoteSource.authors.
 */
+type NoteSource int
+
 const (
-	CatHome = "main.catHome"
-	CatSelf = "main.catSelf"
-	CatNSFW = "main.catNSFW"
+	CatHome  NoteSource = iota // contacts
+	CatSelf                    // ourselves
+	CatNSFW                    // contacts, with content warnings
+	CatUser                    // one user given by npub, nprofile or NIP-05
+	CatRelay                   // every author on one relay
 )
 
+// needsTarget reports whether the source takes a user or a relay as args[2].
+func (src NoteSource) needsTarget() bool {
+	return src == CatUser || src == CatRelay
+}
+
 // }}}
 
 /*
@@ -39,8 +52,10 @@ type Filters struct {
 // }}}
 
 type Recieve struct {
-	RelayUrl string      // data.Relay.URL
-	Event    nostr.Event // data.Event
+	RelayUrl  string            // data.Relay.URL
+	Event     nostr.Event       // data.Event
+	Reactions *Reactions        `json:",omitempty"`
+	Status    map[string]string `json:",omitempty"` // NIP-38 status of the author
 }
 
 type UserFilter struct {
@@ -138,113 +153,248 @@ func (uf *UserFilter) replaceUserFilter(ev nostr.RelayEvent) (string, error) {
 /* getNote {{{
  */
 
-func getNote(args []string, cc confClass) error {
-	var ut int64 = 0
+func getNote(args []string, cc confClass, src NoteSource) error {
 	//var wb []NOSTRLOG
 
-	pc, _, _, _ := runtime.Caller(1)
-	fn := runtime.FuncForPC(pc)
-
 	var uf UserFilter
 	if err := uf.readUserFilter(cc); err != nil {
 		return nil
 	}
 
+	args, withReactions := extractFlag(args, reactionsFlag)
+	args, noStatus := extractFlag(args, noStatusFlag)
+	args, withTags := extractFlag(args, followedTagsFlag)
+	target := ""
+	if src.needsTarget() {
+		if len(args) < 3 {
+			return errors.New("Not set user or relay")
+		}
+		target = args[2]
+		args = append(args[:2:2], args[3:]...)
+	}
+
 	c := cc.getConf()
-	num := c.Settings.DefaultReadNo
+	num, ut, err := parseTimelineArgs(args[2:], c.Settings.DefaultReadNo)
+	if err != nil {
+		return err
+	}
 
-	for i := range args {
-		if i < 2 {
-			continue
+	npub, rs, err := src.authors(cc, target)
+	if err != nil {
+		return err
+	}
+
+	mf := loadMuteFilter(cc)
+
+	filter := noteFilter(num, ut)
+	filter.Authors = npub
+	filters := []nostr.Filter{filter}
+	if withTags && src == CatHome {
+		pk, err := cc.getMySelfHexPubkey()
+		if err != nil {
+			return err
 		}
-		switch i {
-		case 2:
-			tmpnum, err := strconv.Atoi(args[2])
-			if err != nil {
-				tp, err := time.Parse(layout, args[2])
-				if err != nil {
-					return errors.New("An unknown argument was specified.")
-				} else {
-					ut = tp.Unix()
-				}
-			} else {
-				num = tmpnum
-			}
-		case 3:
-			tptmp, err := time.Parse(layout, args[3])
-			if err != nil {
-				num, err = strconv.Atoi(args[3])
-				if err != nil {
-					return errors.New("An unknown argument was specified.")
-				}
-			} else {
-				ut = tptmp.Unix()
-			}
+		tags, err := loadFollowedTags(pk, cc)
+		if err != nil {
+			return err
+		}
+		if 0 < len(tags) {
+			tf := noteFilter(num, ut)
+			tf.Tags = nostr.TagMap{"t": tags}
+			filters = append(filters, tf)
 		}
 	}
+	return showNotes(cc, rs, filters, num, withReactions, !noStatus, mf)
+}
+
+/* NoteSource.authors {{{
 
+WHAT'S THIS?
+Returns the authors of the timeline of src and the relays to read it
+from. nil authors means every author.
+	CatUser:  the read relays, the relay hints of nprofile or NIP-05 and
+	          the write relays of the NIP-65 relay list of the user
+	CatRelay: only the relay of target
+*/
+func (src NoteSource) authors(cc confClass, target string) ([]string, []string, error) {
 	var rs []string
-	if err := cc.getRelayList(&rs, readFlag); err != nil {
-		return err
+	if src != CatRelay {
+		if err := cc.getRelayList(&rs, readFlag); err != nil {
+			return nil, nil, err
+		}
 	}
+
 	var npub []string
-	if fn.Name() == CatHome || fn.Name() == CatNSFW {
+	switch src {
+	case CatHome, CatNSFW:
 		if err := cc.getContactList(&npub); err != nil {
-			return err
+			return nil, nil, err
 		}
-	} else if fn.Name() == CatSelf {
+	case CatSelf:
 		if err := cc.getMySelfPubkey(&npub); err != nil {
-			return err
+			return nil, nil, err
 		}
-	} else {
-		return errors.New("The getNote function is called from a function that cannot use it.")
+	case CatUser:
+		pk, hints, err := resolveUser(target, parseDurationOr(cc.ConfData.Settings.ConnectTimeout, defaultConnectTimeout))
+		if err != nil {
+			return nil, nil, err
+		}
+		rs = uniqueRelays(append(rs, hints...))
+		rs = uniqueRelays(append(rs, authorWriteRelays(cc, rs, pk)...))
+		npub = []string{pk}
+	case CatRelay:
+		url := nostr.NormalizeURL(target)
+		if !strings.HasPrefix(url, "ws") {
+			return nil, nil, fmt.Errorf("Invalid relay url %v", target)
+		}
+		rs = []string{url}
+	default:
+		return nil, nil, errors.New("The getNote function is called from a function that cannot use it.")
+	}
+	return npub, rs, nil
+}
+
+/*
+resolveUser returns the hex public key and the relay hints of an npub,
+nprofile or NIP-05 identifier (name@example.com).
+*/
+func resolveUser(user string, timeout time.Duration) (string, []string, error) {
+	if strings.HasPrefix(user, "nprofile") {
+		if _, data, err := toHex(user); err == nil {
+			if pp, ok := data.(nostr.ProfilePointer); ok {
+				return pp.PublicKey, pp.Relays, nil
+			}
+		}
+		return "", nil, fmt.Errorf("Invalid nprofile %v", user)
+	}
+	if nip05.IsValidIdentifier(user) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		pp, err := nip05.QueryIdentifier(ctx, user)
+		if err != nil {
+			return "", nil, fmt.Errorf("%v: %w", user, err)
+		}
+		return pp.PublicKey, pp.Relays, nil
+	}
+	pk, err := toHexId(user, "npub")
+	return pk, nil, err
+}
+
+// }}}
+
+/*
+parseTimelineArgs reads the optional [number] [date] of timelines in
+either order. num is def and ut is 0 when not given.
+Arguments after the second are ignored.
+*/
+func parseTimelineArgs(args []string, def int) (num int, ut int64, err error) {
+	num = def
+	if 2 < len(args) {
+		args = args[:2]
 	}
+	for _, arg := range args {
+		if tmpnum, err := strconv.Atoi(arg); err == nil {
+			num = tmpnum
+		} else if tp, err := time.Parse(layout, arg); err == nil {
+			ut = tp.Unix()
+		} else {
+			return 0, 0, errors.New("An unknown argument was specified.")
+		}
+	}
+	return num, ut, nil
+}
 
-	var filters []nostr.Filter
+// noteFilter is the kind 1 filter of a timeline of num notes until ut.
+func noteFilter(num int, ut int64) nostr.Filter {
+	f := nostr.Filter{
+		Kinds: []int{nostr.KindTextNote},
+		Limit: num,
+	}
 	if ut > 0 {
 		ts := nostr.Timestamp(ut)
-		filters = []nostr.Filter{{
-			Kinds:   []int{nostr.KindTextNote},
-			Authors: npub,
-			Until:   &ts,
-			Limit:   num,
-		}}
-	} else {
-		filters = []nostr.Filter{{
-			Kinds:   []int{nostr.KindTextNote},
-			Authors: npub,
-			Limit:   num,
-		}}
+		f.Until = &ts
 	}
+	return f
+}
+
+// }}}
 
+/* showNotes {{{
+
+WHAT'S THIS?
+Reads the notes of filters from rs and displays the newest num of
+them as a timeline, with the status of each author unless --no-status and, with
+--reactions, the reaction tallies. Muted and expired notes are left out.
+*/
+func showNotes(cc confClass, rs []string, filters []nostr.Filter, num int, withReactions, withStatus bool, mf MuteFilter) error {
+	c := cc.getConf()
 	ctx := context.Background()
-	pool := nostr.NewSimplePool(ctx)
+	pool := cc.newPool(ctx, rs)
 	ctx, cancel := context.WithCancel(ctx)
 	recieveData := []Recieve{}
+	var mu sync.Mutex
 	defer cancel()
 
 	wt := time.Duration(int64(math.Ceil(float64(num)*c.Settings.MultiplierReadRelayWaitTime))) * time.Second
 	timer := time.NewTimer(wt)
 	defer timer.Stop()
 	reb := replaceEnginForBech32{}
-	go func() error {
-		ch := pool.SubManyEose(ctx, rs, filters)
+	go func() {
+		ch := cc.subManyEose(ctx, pool, rs, filters)
 		for event := range ch {
-			conv := convertRelayEventToRecieve(&event)
-			if tmp, err := reb.replaceToBech32(conv); err != nil {
-				return err
-			} else {
-				recieveData = append(recieveData, tmp)
+			if mf.isMuted(event.Event) || isExpired(event.Tags, nostr.Now()) {
+				continue
 			}
+			mu.Lock()
+			recieveData = append(recieveData, convertRelayEventToRecieve(&event))
+			mu.Unlock()
 		}
-		return nil
 	}()
 	select {
 	case <-timer.C:
-		sort.Slice(recieveData, func(i, j int) bool {
-			return recieveData[i].Event.CreatedAt > recieveData[j].Event.CreatedAt
+		mu.Lock()
+		notes := append([]Recieve{}, recieveData...)
+		mu.Unlock()
+		sort.Slice(notes, func(i, j int) bool {
+			return notes[i].Event.CreatedAt > notes[j].Event.CreatedAt
 		})
-		if data, err := json5.Marshal(recieveData); err != nil {
+		// each filter (and each relay) returns up to num notes
+		if 0 < num && num < len(notes) {
+			notes = notes[:num]
+		}
+		cc.cacheNotes(notes)
+		if withStatus {
+			authors := []string{}
+			seen := make(map[string]struct{})
+			for i := range notes {
+				if _, ok := seen[notes[i].Event.PubKey]; !ok {
+					seen[notes[i].Event.PubKey] = struct{}{}
+					authors = append(authors, notes[i].Event.PubKey)
+				}
+			}
+			statuses := fetchStatuses(ctx, cc, pool, rs, authors, wt)
+			for i := range notes {
+				notes[i].Status = statuses[notes[i].Event.PubKey]
+			}
+		}
+		if withReactions {
+			ids := []string{}
+			for i := range notes {
+				ids = append(ids, notes[i].Event.ID)
+			}
+			tally := fetchReactions(ctx, cc, pool, rs, ids, wt, mf)
+			for i := range notes {
+				notes[i].Reactions = tally[notes[i].Event.ID]
+			}
+		}
+		for i := range notes {
+			if tmp, err := reb.replaceToBech32(notes[i]); err != nil {
+				return err
+			} else {
+				notes[i] = tmp
+			}
+		}
+		if data, err := json5.Marshal(notes); err != nil {
 			return err
 		} else {
 			fmt.Printf("%v", string(data))
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestParseTimelineArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		num     int
+		ut      int64
+		wantErr bool
+	}{
+		{[]string{}, 20, 0, false},
+		{[]string{"5"}, 5, 0, false},
+		{[]string{"2024/01/02 15:04:05 UTC", "5"}, 5, 1704207845, false},
+		{[]string{"5", "2024/01/02 15:04:05 UTC"}, 5, 1704207845, false},
+		{[]string{"yesterday"}, 0, 0, true},
+	}
+	for _, tc := range tests {
+		num, ut, err := parseTimelineArgs(tc.args, 20)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%v: err = %v", tc.args, err)
+			continue
+		}
+		if !tc.wantErr && (num != tc.num || ut != tc.ut) {
+			t.Errorf("%v: got %d, %d, want %d, %d", tc.args, num, ut, tc.num, tc.ut)
+		}
+	}
+}
+
+// withUserFilter writes the empty filters.json that getNote reads first.
+func withUserFilter(t *testing.T, cc *confClass) {
+	cc.ConfData.Filename.Filters = "filters.json"
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, "filters.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveUser(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+	nprofile, _ := nip19.EncodeProfile(pk, []string{"wss://hint.example.com"})
+
+	for _, user := range []string{npub, pk, nprofile} {
+		got, hints, err := resolveUser(user, time.Second)
+		if err != nil || got != pk {
+			t.Errorf("resolveUser(%v) = %v, %v", user, got, err)
+		}
+		if user == nprofile && (len(hints) != 1 || hints[0] != "wss://hint.example.com") {
+			t.Errorf("hints of nprofile = %v", hints)
+		}
+	}
+	if _, _, err := resolveUser("note1xyz", time.Second); err == nil {
+		t.Error("note was accepted as a user")
+	}
+}
+
+func TestCatUserAndRelay(t *testing.T) {
+	alice := signedEvent(t, "from alice")
+	bob := signedEvent(t, "from bob")
+	r := newTestRelay(t, &testRelay{Stored: []nostr.Event{alice, bob}})
+	url := r.URL
+	cc := setupTestEnv(t, map[string]RwFlag{url: {Read: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.1
+	cc.ConfData.Settings.DefaultReadNo = 10
+	withUserFilter(t, &cc)
+
+	npub, _ := nip19.EncodePublicKey(alice.PubKey)
+	if err := catUser([]string{"nostk", "catUser", npub}, cc); err != nil {
+		t.Fatal(err)
+	}
+	evs, _ := cc.loadEventCache()
+	if len(evs) != 1 || evs[0].ID != alice.ID {
+		t.Errorf("catUser read %v, want only the notes of the user", evs)
+	}
+
+	// catRelay reads every author from the relay, not only the read relays
+	other := setupTestEnv(t, map[string]RwFlag{})
+	other.ConfData.Settings.MultiplierReadRelayWaitTime = 0.1
+	other.ConfData.Settings.DefaultReadNo = 10
+	withUserFilter(t, &other)
+	if err := catRelay([]string{"nostk", "catRelay", url, "10"}, other); err != nil {
+		t.Fatal(err)
+	}
+	evs, _ = other.loadEventCache()
+	if len(evs) != 2 {
+		t.Errorf("catRelay read %d notes, want 2", len(evs))
+	}
+
+	// the timeline is trimmed to the number of notes asked for
+	trimmed := setupTestEnv(t, map[string]RwFlag{})
+	trimmed.ConfData.Settings.MultiplierReadRelayWaitTime = 0.1
+	withUserFilter(t, &trimmed)
+	if err := catRelay([]string{"nostk", "catRelay", url, "1"}, trimmed); err != nil {
+		t.Fatal(err)
+	}
+	evs, _ = trimmed.loadEventCache()
+	if len(evs) != 1 {
+		t.Errorf("catRelay 1 showed %d notes", len(evs))
+	}
+
+	// statuses are read unless --no-status
+	n := r.requested(nostr.KindUserStatuses)
+	if n < 1 {
+		t.Error("no status filters sent")
+	}
+	if err := catRelay([]string{"nostk", "catRelay", url, "1", "--no-status"}, trimmed); err != nil {
+		t.Fatal(err)
+	}
+	if m := r.requested(nostr.KindUserStatuses); m != n {
+		t.Errorf("%d status filters sent with --no-status", m-n)
+	}
+
+	if err := catRelay([]string{"nostk", "catRelay"}, cc); err == nil {
+		t.Error("catRelay without url succeeded")
+	}
+	if err := catRelay([]string{"nostk", "catRelay", "ftp://example.com"}, cc); err == nil {
+		t.Error("catRelay accepted an invalid url")
+	}
+}
@@ -3,9 +3,11 @@ module github.com/mitsugu/nostk
 go 1.24.1
 
 require (
+	github.com/coder/websocket v1.8.12
 	github.com/mattn/go-jsonpointer v0.0.1
 	github.com/nbd-wtf/go-nostr v0.51.11
 	github.com/yosuke-furukawa/json5 v0.1.1
+	golang.org/x/image v0.36.0
 )
 
 require (
@@ -16,7 +18,6 @@ require (
 	github.com/bytedance/sonic v1.13.1 // indirect
 	github.com/bytedance/sonic/loader v0.2.4 // indirect
 	github.com/cloudwego/base64x v0.1.5 // indirect
-	github.com/coder/websocket v1.8.12 // indirect
 	github.com/decred/dcrd/crypto/blake256 v1.1.0 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
@@ -31,6 +32,7 @@ require (
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	golang.org/x/arch v0.15.0 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/exp v0.0.0-20250305212735-054e65f0b394 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 )
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	followedTagsFlag = "--tags"
+)
+
+/* normalizeHashTag {{{
+
+WHAT'S THIS?
+Converts "#Nostr" (or "＃nostr") to the "t" tag value "nostr".
+setHashTags only writes lower case values, so followed tags are
+compared in lower case too.
+*/
+func normalizeHashTag(s string) string {
+	s = strings.TrimLeft(strings.TrimSpace(s), "#﹟＃")
+	return strings.ToLower(s)
+}
+
+// }}}
+
+/* loadInterests {{{
+
+WHAT'S THIS?
+Reads the newest kind 10015 event of pk from the read relays and
+returns all its tags ("t" hashtags, "a" interest sets ...) in list
+order, so that the tags nostk does not edit are published back as is.
+An empty list is returned when nothing has been published yet; see
+fetchReplaceableList for force.
+*/
+func loadInterests(pk string, cc confClass, force bool) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	ev, err := fetchReplaceableList(cc, nostr.KindInterestList, pk, force)
+	if err != nil || ev == nil {
+		return tgs, err
+	}
+	for _, tg := range ev.Tags {
+		if 0 < len(tg) {
+			tgs = append(tgs, tg)
+		}
+	}
+	return tgs, nil
+}
+
+// loadFollowedTags returns the hashtags of the kind 10015 of pk.
+func loadFollowedTags(pk string, cc confClass) ([]string, error) {
+	// nothing is published here, so no answer is no hashtags
+	tgs, err := loadInterests(pk, cc, true)
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	for _, tg := range tgs {
+		if 1 < len(tg) && tg[indexTagName] == "t" {
+			tags = append(tags, tg[1])
+		}
+	}
+	return tags, nil
+}
+
+// }}}
+
+/* publishInterests {{{
+ */
+func publishInterests(tgs nostr.Tags, cc confClass) error {
+	dataRawArg := RawArg{
+		Kind:    nostr.KindInterestList,
+		Content: "",
+		Tags:    tgs,
+	}
+	return publishRawArg(dataRawArg, keptTags, cc)
+}
+
+// }}}
+
+/*
+	followTag {{{
+		[infomation for develop]
+		usage:
+			nostk followTag <tag...> [--force]
+		kind: 10015
+		content: ""
+		tags [
+			"t": hashtag without "#"
+		]
+*/
+func followTag(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) < 3 {
+		return errors.New("Not set hashtags")
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadInterests(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	added := 0
+	for _, arg := range args[2:] {
+		tag := normalizeHashTag(arg)
+		if tag == "" {
+			return fmt.Errorf("Invalid hashtag %q", arg)
+		}
+		tg := nostr.Tag{"t", tag}
+		if 0 <= indexOfTag(tgs, tg) {
+			fmt.Printf("#%v is already followed\n", tag)
+			continue
+		}
+		tgs = append(tgs, tg)
+		added++
+	}
+	if added < 1 {
+		return errors.New("No hashtags to follow")
+	}
+	return publishInterests(tgs, cc)
+}
+
+// }}}
+
+/*
+	unfollowTag {{{
+		[infomation for develop]
+		usage:
+			nostk unfollowTag <tag...> [--force]
+*/
+func unfollowTag(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) < 3 {
+		return errors.New("Not set hashtags")
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadInterests(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args[2:] {
+		tag := normalizeHashTag(arg)
+		var removed bool
+		tgs, removed = removeTag(tgs, nostr.Tag{"t", tag})
+		if removed == false {
+			return fmt.Errorf("#%v is not followed", tag)
+		}
+	}
+	return publishInterests(tgs, cc)
+}
+
+// }}}
+
+/*
+	catFollowedTags {{{
+		[infomation for develop]
+		usage:
+			nostk catFollowedTags [npub]
+*/
+func catFollowedTags(args []string, cc confClass) error {
+	var pk string
+	var err error
+	switch len(args) {
+	case 2:
+		if pk, err = cc.getMySelfHexPubkey(); err != nil {
+			return err
+		}
+	case 3:
+		if pk, err = toHexId(args[2], "npub", "nprofile"); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+	tags, err := loadFollowedTags(pk, cc)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Printf("#%v\n", tag)
+	}
+	return nil
+}
+
+// }}}
+
+/*
+	catTag {{{
+		[infomation for develop]
+		usage:
+			nostk catTag <tag...> [number] [date] [--reactions] [--no-status]
+		Displays kind 1 notes with any of the hashtags ("#t" filter).
+		A tag made only of digits must be written with "#" ("#2024"),
+		otherwise it is read as the number of notes.
+*/
+func catTag(args []string, cc confClass) error {
+	args, withReactions := extractFlag(args, reactionsFlag)
+	args, noStatus := extractFlag(args, noStatusFlag)
+	tags, rest := splitTagArgs(args[2:])
+	if len(tags) < 1 {
+		return errors.New("Not set hashtags")
+	}
+
+	c := cc.getConf()
+	num, ut, err := parseTimelineArgs(rest, c.Settings.DefaultReadNo)
+	if err != nil {
+		return err
+	}
+
+	var rs []string
+	if err := cc.getRelayList(&rs, readFlag); err != nil {
+		return err
+	}
+	mf := loadMuteFilter(cc)
+	filter := noteFilter(num, ut)
+	filter.Tags = nostr.TagMap{"t": tags}
+	return showNotes(cc, rs, []nostr.Filter{filter}, num, withReactions, !noStatus, mf)
+}
+
+/*
+splitTagArgs separates the hashtags of catTag from the [number] [date]
+that follow them.
+*/
+func splitTagArgs(args []string) (tags []string, rest []string) {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "#") {
+			if _, err := strconv.Atoi(arg); err == nil {
+				rest = append(rest, arg)
+				continue
+			}
+			if _, err := time.Parse(layout, arg); err == nil {
+				rest = append(rest, arg)
+				continue
+			}
+		}
+		if tag := normalizeHashTag(arg); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, rest
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
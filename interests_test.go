@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestNormalizeHashTag(t *testing.T) {
+	for in, want := range map[string]string{
+		"nostr":   "nostr",
+		"#Nostr":  "nostr",
+		"＃ノスター":   "ノスター",
+		" #2024 ": "2024",
+		"#":       "",
+	} {
+		if got := normalizeHashTag(in); got != want {
+			t.Errorf("normalizeHashTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSplitTagArgs(t *testing.T) {
+	tags, rest := splitTagArgs([]string{"nostr", "#2024", "#Go", "20", "2024/01/02 15:04:05 JST"})
+	if !reflect.DeepEqual(tags, []string{"nostr", "2024", "go"}) {
+		t.Errorf("tags = %v", tags)
+	}
+	if !reflect.DeepEqual(rest, []string{"20", "2024/01/02 15:04:05 JST"}) {
+		t.Errorf("rest = %v", rest)
+	}
+}
+
+func TestLoadFollowedTags(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	list := nostr.Event{
+		Kind:      nostr.KindInterestList,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"t", "nostr"},
+			{"a", "30015:0000:coffee"},
+			{"t", "go"},
+			{"alt", "interests"},
+		},
+	}
+	list.Sign(sk)
+	url := newTestRelay(t, &testRelay{Stored: []nostr.Event{list}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{url: {Read: true}})
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, ".hsec"), []byte(sk), 0600)
+	pk, _ := nostr.GetPublicKey(sk)
+
+	tags, err := loadFollowedTags(pk, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"nostr", "go"}) {
+		t.Errorf("followed tags = %v", tags)
+	}
+	// interest sets and unknown tags are kept when the list is published back
+	tgs, err := loadInterests(pk, cc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 4 {
+		t.Errorf("interests = %v", tgs)
+	}
+
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc = setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+	if err := followTag([]string{"nostk", "followTag", "nostr"}, cc); err == nil {
+		t.Error("followed without reading the list")
+	}
+	if tags, err := loadFollowedTags(pk, cc); err != nil || len(tags) != 0 {
+		t.Errorf("followed tags without an answer = %v, %v", tags, err)
+	}
+}
+
+func TestCatTag(t *testing.T) {
+	tagged := signedEvent(t, "#nostr is fun")
+	tagged.Tags = nostr.Tags{{"t", "nostr"}}
+	tagged.Sign(nostr.GeneratePrivateKey())
+	other := signedEvent(t, "no hashtag")
+	url := newTestRelay(t, &testRelay{Stored: []nostr.Event{tagged, other}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{url: {Read: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.1
+
+	if err := catTag([]string{"nostk", "catTag", "#Nostr", "10"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := cc.loadEventCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != tagged.ID {
+		t.Errorf("catTag read %v, want only the tagged note", evs)
+	}
+	if err := catTag([]string{"nostk", "catTag", "10"}, cc); err == nil {
+		t.Error("catTag without hashtags succeeded")
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip44"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	//"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	privateFlag = "--private"
+)
+
+/*
+mute list structure {{{
+
+Public entries are the tags of the kind 10000 event.
+Private entries are NIP-44 encrypted to ourselves and stored in content.
+*/
+type MuteList struct {
+	Public  nostr.Tags `json:"public"`
+	Private nostr.Tags `json:"private"`
+}
+
+// }}}
+
+/* mute entry types {{{
+
+WHAT'S THIS?
+Maps each tag name of the mute list to the function that normalizes
+the value given on the command line.
+*/
+type MuteTypeTbl map[string]func(string) (string, error)
+
+func NewMuteTypeTbl() MuteTypeTbl {
+	return MuteTypeTbl{
+		"p": func(s string) (string, error) {
+			return toHexId(s, "npub", "nprofile")
+		},
+		"e": func(s string) (string, error) {
+			return toHexId(s, "note", "nevent")
+		},
+		"t": func(s string) (string, error) {
+			return strings.ToLower(strings.TrimLeft(s, "#﹟＃")), nil
+		},
+		"word": func(s string) (string, error) {
+			return strings.ToLower(s), nil
+		},
+	}
+}
+func (r MuteTypeTbl) mkTag(tagName string, value string) (nostr.Tag, error) {
+	f, exists := r[tagName]
+	if !exists {
+		return nil, fmt.Errorf("Not supported mute type %v", tagName)
+	}
+	v, err := f(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 1 {
+		return nil, errors.New("Empty mute entry")
+	}
+	return nostr.Tag{tagName, v}, nil
+}
+
+// }}}
+
+/* MuteList methods {{{
+ */
+func indexOfTag(tgs nostr.Tags, tg nostr.Tag) int {
+	for i := range tgs {
+		if len(tgs[i]) < 2 {
+			continue
+		}
+		if tgs[i][indexTagName] == tg[indexTagName] && tgs[i][1] == tg[1] {
+			return i
+		}
+	}
+	return -1
+}
+func removeTag(tgs nostr.Tags, tg nostr.Tag) (nostr.Tags, bool) {
+	i := indexOfTag(tgs, tg)
+	if i < 0 {
+		return tgs, false
+	}
+	return append(tgs[:i], tgs[i+1:]...), true
+}
+func (ml *MuteList) add(tg nostr.Tag, private bool) {
+	ml.remove(tg)
+	if private {
+		ml.Private = append(ml.Private, tg)
+	} else {
+		ml.Public = append(ml.Public, tg)
+	}
+}
+func (ml *MuteList) remove(tg nostr.Tag) bool {
+	var pub, priv bool
+	ml.Public, pub = removeTag(ml.Public, tg)
+	ml.Private, priv = removeTag(ml.Private, tg)
+	return pub || priv
+}
+
+// }}}
+
+/* MuteFilter {{{
+
+WHAT'S THIS?
+Decides whether a received event should be hidden from the output.
+*/
+type MuteFilter struct {
+	pubkeys  map[string]struct{}
+	events   map[string]struct{}
+	hashtags map[string]struct{}
+	words    []string
+}
+
+func (ml MuteList) filter() MuteFilter {
+	mf := MuteFilter{
+		pubkeys:  map[string]struct{}{},
+		events:   map[string]struct{}{},
+		hashtags: map[string]struct{}{},
+	}
+	for _, tgs := range []nostr.Tags{ml.Public, ml.Private} {
+		for _, tg := range tgs {
+			if len(tg) < 2 {
+				continue
+			}
+			switch tg[indexTagName] {
+			case "p":
+				mf.pubkeys[tg[1]] = struct{}{}
+			case "e":
+				mf.events[tg[1]] = struct{}{}
+			case "t":
+				mf.hashtags[strings.ToLower(tg[1])] = struct{}{}
+			case "word":
+				mf.words = append(mf.words, strings.ToLower(tg[1]))
+			}
+		}
+	}
+	return mf
+}
+func (mf MuteFilter) isMuted(ev *nostr.Event) bool {
+	if _, ok := mf.pubkeys[ev.PubKey]; ok {
+		return true
+	}
+	if _, ok := mf.events[ev.ID]; ok {
+		return true
+	}
+	for _, tg := range ev.Tags {
+		if len(tg) < 2 {
+			continue
+		}
+		switch tg[indexTagName] {
+		case "e": // muted thread
+			if _, ok := mf.events[tg[1]]; ok {
+				return true
+			}
+		case "t":
+			if _, ok := mf.hashtags[strings.ToLower(tg[1])]; ok {
+				return true
+			}
+		}
+	}
+	content := strings.ToLower(ev.Content)
+	for _, w := range mf.words {
+		if strings.Contains(content, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+/* encryptPrivateTags / decryptPrivateTags {{{
+
+WHAT'S THIS?
+Private entries are encrypted to our own public key.
+Old clients used NIP-04, so "?iv=" content is decrypted with NIP-04.
+*/
+func encryptPrivateTags(tgs nostr.Tags, sk string, pk string) (string, error) {
+	if len(tgs) < 1 {
+		return "", nil
+	}
+	b, err := json.Marshal(tgs)
+	if err != nil {
+		return "", err
+	}
+	key, err := nip44.GenerateConversationKey(pk, sk)
+	if err != nil {
+		return "", err
+	}
+	return nip44.Encrypt(string(b), key)
+}
+func decryptPrivateTags(content string, sk string, pk string) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	if len(content) < 1 {
+		return tgs, nil
+	}
+	var plain string
+	if strings.Contains(content, "?iv=") {
+		key, err := nip04.ComputeSharedSecret(pk, sk)
+		if err != nil {
+			return nil, err
+		}
+		if plain, err = nip04.Decrypt(content, key); err != nil {
+			return nil, err
+		}
+	} else {
+		key, err := nip44.GenerateConversationKey(pk, sk)
+		if err != nil {
+			return nil, err
+		}
+		if plain, err = nip44.Decrypt(content, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(plain), &tgs); err != nil {
+		return nil, err
+	}
+	return tgs, nil
+}
+
+// }}}
+
+/* loadMuteList {{{
+
+WHAT'S THIS?
+Reads our newest kind 10000 event from the read relays to be edited.
+Every public tag is kept, also the ones nostk does not write, so that
+publishing the list again does not drop entries of other clients.
+An empty list is returned when nothing has been published yet; see
+fetchReplaceableList for force.
+*/
+func loadMuteList(cc confClass, force bool) (MuteList, error) {
+	ml := MuteList{Public: nostr.Tags{}, Private: nostr.Tags{}}
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Println("Nothing key pair. Make key pair.")
+		return ml, err
+	}
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return ml, err
+	}
+
+	ev, err := fetchReplaceableList(cc, nostr.KindMuteList, pk, force)
+	if err != nil || ev == nil {
+		return ml, err
+	}
+	for _, tg := range ev.Tags {
+		if 0 < len(tg) {
+			ml.Public = append(ml.Public, tg)
+		}
+	}
+	if ml.Private, err = decryptPrivateTags(ev.Content, sk, pk); err != nil {
+		// publishing without them would lose the private entries
+		return ml, fmt.Errorf("Can not decrypt the private mute entries: %w", err)
+	}
+	return ml, nil
+}
+
+// }}}
+
+/* loadMuteFilter {{{
+
+WHAT'S THIS?
+Returns the filter of our mute list for the read subcommands.
+A read never fails because of the mute list: problems are printed to
+stderr and the entries that could be read are used. The filter is
+read once per process when cc has a MuteFilterCache.
+*/
+type MuteFilterCache struct {
+	once sync.Once
+	mf   MuteFilter
+}
+
+func (cc *confClass) setMuteFilterCache() {
+	cc.mutes = &MuteFilterCache{}
+}
+
+func loadMuteFilter(cc confClass) MuteFilter {
+	if cc.mutes == nil {
+		return readMuteFilter(cc)
+	}
+	cc.mutes.once.Do(func() {
+		cc.mutes.mf = readMuteFilter(cc)
+	})
+	return cc.mutes.mf
+}
+
+func readMuteFilter(cc confClass) MuteFilter {
+	ml := MuteList{}
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Mute list is not applied: %v\n", err)
+		return ml.filter()
+	}
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Mute list is not applied: %v\n", err)
+		return ml.filter()
+	}
+	// a missing answer is no mute list here; nothing is published from it
+	ev, err := fetchReplaceableList(cc, nostr.KindMuteList, pk, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Mute list is not applied: %v\n", err)
+		return ml.filter()
+	}
+	if ev == nil {
+		return ml.filter()
+	}
+	ml.Public = ev.Tags
+	if ml.Private, err = decryptPrivateTags(ev.Content, sk, pk); err != nil {
+		fmt.Fprintf(os.Stderr, "Private mute entries are not applied: %v\n", err)
+	}
+	return ml.filter()
+}
+
+// }}}
+
+/* publishMuteList {{{
+ */
+func publishMuteList(ml MuteList, cc confClass) error {
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Println("Nothing key pair. Make key pair.")
+		return err
+	}
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return err
+	}
+
+	dataRawArg := RawArg{
+		Kind: nostr.KindMuteList,
+		Tags: ml.Public,
+	}
+	if dataRawArg.Content, err = encryptPrivateTags(ml.Private, sk, pk); err != nil {
+		return err
+	}
+	return publishRawArg(dataRawArg, keptTags, cc)
+}
+
+// }}}
+
+/*
+	mute {{{
+		[infomation for develop]
+		usage:
+			nostk mute <p|e|t|word> <value> [--private] [--force]
+		kind: 10000
+		content: NIP-44 encrypted private entries
+		tags [
+			"p": pubkey (hex)
+			"e": thread's event id (hex)
+			"t": hashtag
+			"word": lowercase string
+		]
+*/
+func mute(args []string, cc confClass) error {
+	args, private := extractFlag(args, privateFlag)
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 4 {
+		return errors.New("Wrong number of parameters")
+	}
+	tg, err := NewMuteTypeTbl().mkTag(args[2], args[3])
+	if err != nil {
+		return err
+	}
+
+	ml, err := loadMuteList(cc, force)
+	if err != nil {
+		return err
+	}
+	ml.add(tg, private)
+	return publishMuteList(ml, cc)
+}
+
+// }}}
+
+/*
+	unmute {{{
+		[infomation for develop]
+		usage:
+			nostk unmute <p|e|t|word> <value> [--force]
+*/
+func unmute(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 4 {
+		return errors.New("Wrong number of parameters")
+	}
+	tg, err := NewMuteTypeTbl().mkTag(args[2], args[3])
+	if err != nil {
+		return err
+	}
+
+	ml, err := loadMuteList(cc, force)
+	if err != nil {
+		return err
+	}
+	if ml.remove(tg) == false {
+		return fmt.Errorf("%v %v is not in the mute list", args[2], args[3])
+	}
+	return publishMuteList(ml, cc)
+}
+
+// }}}
+
+/* catMutes {{{
+ */
+func catMutes(args []string, cc confClass) error {
+	ml, err := loadMuteList(cc, false)
+	if err != nil {
+		return err
+	}
+	for _, tgs := range []nostr.Tags{ml.Public, ml.Private} {
+		for _, tg := range tgs {
+			switch tg[indexTagName] {
+			case "p":
+				if tmp, err := nip19.EncodePublicKey(tg[1]); err == nil {
+					tg[1] = tmp
+				}
+			case "e":
+				if tmp, err := nip19.EncodeNote(tg[1]); err == nil {
+					tg[1] = tmp
+				}
+			}
+		}
+	}
+	if data, err := json5.Marshal(ml); err != nil {
+		return err
+	} else {
+		fmt.Printf("%v", string(data))
+	}
+	return nil
+}
+
+// }}}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsMuted(t *testing.T) {
+	const (
+		mutedPk  = "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"
+		otherPk  = "4cf6b2efbf6ff7a7e3f3a5db1d7c7b3b7a05ef1bca9d1bbd0f8fa5c0da3fa4ec"
+		threadId = "a3c0e069adeb19d8f59ec4e6b5157b7d3416c08805f9bd4849049325747a8086"
+	)
+	ml := MuteList{
+		Public:  nostr.Tags{{"p", mutedPk}, {"t", "spoiler"}},
+		Private: nostr.Tags{{"e", threadId}, {"word", "secret"}},
+	}
+	mf := ml.filter()
+	tests := []struct {
+		ev    nostr.Event
+		muted bool
+	}{
+		{ev: nostr.Event{PubKey: otherPk, Content: "hello"}, muted: false},
+		{ev: nostr.Event{PubKey: mutedPk, Content: "hello"}, muted: true},
+		{ev: nostr.Event{PubKey: otherPk, Content: "hi", Tags: nostr.Tags{{"t", "Spoiler"}}}, muted: true},
+		{ev: nostr.Event{PubKey: otherPk, Content: "reply", Tags: nostr.Tags{{"e", threadId, "", "root"}}}, muted: true},
+		{ev: nostr.Event{ID: threadId, PubKey: otherPk, Content: "root"}, muted: true},
+		{ev: nostr.Event{PubKey: otherPk, Content: "A SECRET plan"}, muted: true},
+	}
+	for _, tc := range tests {
+		if ret := mf.isMuted(&tc.ev); ret != tc.muted {
+			t.Fatalf("event: %v, got muted: %v, Want muted: %v", tc.ev, ret, tc.muted)
+		}
+	}
+}
+
+func TestPrivateTags(t *testing.T) {
+	sk, pk, err := genHexKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgs := nostr.Tags{{"p", "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"}, {"word", "secret"}}
+	content, err := encryptPrivateTags(tgs, sk, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := decryptPrivateTags(content, sk, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 2 || ret[1][1] != "secret" {
+		t.Fatalf("got tags: %v, Want tags: %v", ret, tgs)
+	}
+}
+
+func TestMkMuteTag(t *testing.T) {
+	tbl := NewMuteTypeTbl()
+	tests := []struct {
+		tagName string
+		value   string
+		want    string
+		err     bool
+	}{
+		{tagName: "p", value: "npub1czyqt7dafpysfye9w3agpp4rcrsxnt0tr8v0t8kyu66327maxstq5ckh7u", want: "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"},
+		{tagName: "t", value: "#Nostr", want: "nostr"},
+		{tagName: "word", value: "Spoiler", want: "spoiler"},
+		{tagName: "e", value: "npub1czyqt7dafpysfye9w3agpp4rcrsxnt0tr8v0t8kyu66327maxstq5ckh7u", err: true},
+		{tagName: "x", value: "value", err: true},
+	}
+	for _, tc := range tests {
+		tg, err := tbl.mkTag(tc.tagName, tc.value)
+		if (err != nil) != tc.err {
+			t.Fatalf("%v %v, got error: %v", tc.tagName, tc.value, err)
+		}
+		if err == nil && tg[1] != tc.want {
+			t.Fatalf("%v %v, got: %v, Want: %v", tc.tagName, tc.value, tg[1], tc.want)
+		}
+	}
+}
+
+func TestLoadMuteList(t *testing.T) {
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc := setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+
+	// no answer is not an empty list
+	if _, err := loadMuteList(cc, false); err == nil {
+		t.Error("the list was replaced when no relay answered")
+	}
+	if ml, err := loadMuteList(cc, true); err != nil || len(ml.Public) != 0 {
+		t.Errorf("--force = %v, %v", ml, err)
+	}
+	// the read path goes on without the list
+	if mf := loadMuteFilter(cc); mf.isMuted(&nostr.Event{Content: "hello"}) {
+		t.Error("nothing should be muted")
+	}
+
+	sk, _ := cc.load(cc.ConfData.Filename.Hsec)
+	pk, _ := nostr.GetPublicKey(sk)
+	content, _ := encryptPrivateTags(nostr.Tags{{"word", "secret"}}, sk, pk)
+	list := nostr.Event{
+		Kind:      nostr.KindMuteList,
+		CreatedAt: nostr.Now(),
+		Content:   content,
+		Tags:      nostr.Tags{{"t", "spoiler"}, {"emoji", "unknown"}},
+	}
+	list.Sign(sk)
+	stored := newTestRelay(t, &testRelay{Stored: []nostr.Event{list}})
+	empty := newTestRelay(t, &testRelay{})
+	for _, url := range []string{stored.URL, empty.URL} {
+		b, _ := json.Marshal(map[string]RwFlag{url: {Read: true}})
+		os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, "relays.json"), b, 0600)
+		ml, err := loadMuteList(cc, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch url {
+		case stored.URL:
+			// tags nostk does not write are kept
+			if len(ml.Public) != 2 || len(ml.Private) != 1 {
+				t.Errorf("mute list = %v", ml)
+			}
+		case empty.URL:
+			if len(ml.Public) != 0 || len(ml.Private) != 0 {
+				t.Errorf("EOSE without the list = %v", ml)
+			}
+		}
+	}
+
+	// a broken private part fails the edit but not the read
+	broken := list
+	broken.Content = "broken"
+	broken.CreatedAt++
+	broken.Sign(sk)
+	other := newTestRelay(t, &testRelay{Stored: []nostr.Event{broken}})
+	b, _ := json.Marshal(map[string]RwFlag{other.URL: {Read: true}})
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, "relays.json"), b, 0600)
+	if _, err := loadMuteList(cc, false); err == nil {
+		t.Error("the list was loaded without its private entries")
+	}
+	if mf := loadMuteFilter(cc); !mf.isMuted(&nostr.Event{Content: "#spoiler", Tags: nostr.Tags{{"t", "spoiler"}}}) {
+		t.Error("public entries are not applied")
+	}
+}
@@ -1,16 +1,13 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
@@ -76,6 +73,34 @@ func main() {
 		log.Fatal(err)
 		os.Exit(1)
 	}
+	if args, err := cc.setMinSuccessOption(os.Args); err != nil {
+		log.Fatal(err)
+	} else {
+		os.Args = args
+	}
+	cc.setMuteFilterCache()
+
+	if args, err := cc.setPowOption(os.Args); err != nil {
+		log.Fatal(err)
+	} else {
+		os.Args = args
+	}
+	if args, err := cc.setExpireOption(os.Args); err != nil {
+		log.Fatal(err)
+	} else {
+		os.Args = args
+	}
+	if args, err := cc.setPublishModeFlags(os.Args); err != nil {
+		log.Fatal(err)
+	} else {
+		os.Args = args
+	}
+	os.Args = cc.setVerifyFlags(os.Args)
+	// "nostk --dry-run" has no subcommand left
+	if len(os.Args) < 2 {
+		dispHelp()
+		os.Exit(0)
+	}
 
 	switch os.Args[1] {
 	case "help":
@@ -112,6 +137,11 @@ func main() {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "editBlossom":
+		if err := cc.edit(cc.ConfData.Filename.Blossom); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "editContacts":
 		if err := cc.edit(cc.ConfData.Filename.Contacts); err != nil {
 			log.Fatal(err)
@@ -122,6 +152,11 @@ func main() {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "relayInfo":
+		if err := relayInfo(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "pubRelays":
 		if err := publishRelayList(cc); err != nil {
 			log.Fatal(err)
@@ -137,6 +172,56 @@ func main() {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "pubPicture":
+		if err := pubPicture(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "pubArticle":
+		if err := pubArticle(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "draft":
+		if err := draft(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "schedule":
+		if err := schedule(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "queue":
+		if err := queue(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "outbox":
+		if err := outbox(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "export":
+		if err := export(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "import":
+		if err := importArchive(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "rebroadcast":
+		if err := rebroadcast(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "broadcast":
+		if err := broadcast(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "pubRaw":
 		if err := publishRaw(os.Args, cc); err != nil {
 			log.Fatal(err)
@@ -147,6 +232,11 @@ func main() {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "catArticles":
+		if err := catArticles(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "catSelf":
 		if err := catSelf(os.Args, cc); err != nil {
 			log.Fatal(err)
@@ -157,16 +247,151 @@ func main() {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "catUser":
+		if err := catUser(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "catRelay":
+		if err := catRelay(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "catEvent":
 		if err := catEvent(os.Args, cc); err != nil {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "catTag":
+		if err := catTag(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "followTag":
+		if err := followTag(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "unfollowTag":
+		if err := unfollowTag(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "catFollowedTags":
+		if err := catFollowedTags(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "search":
+		if err := search(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "addSearchRelay":
+		if err := addSearchRelay(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "removeSearchRelay":
+		if err := removeSearchRelay(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "catSearchRelays":
+		if err := catSearchRelays(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "emojiReaction":
 		if err := emojiReaction(os.Args, cc); err != nil {
 			log.Fatal(err)
 			os.Exit(1)
 		}
+	case "mute":
+		if err := mute(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "unmute":
+		if err := unmute(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "catMutes":
+		if err := catMutes(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "pin":
+		if err := pin(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "unpin":
+		if err := unpin(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "catPins":
+		if err := catPins(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "pubEmojiSet":
+		if err := pubEmojiSet(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "addEmojiSet":
+		if err := addEmojiSet(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "removeEmojiSet":
+		if err := removeEmojiSet(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "syncEmoji":
+		if err := syncEmoji(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "setStatus":
+		if err := setStatus(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "clearStatus":
+		if err := clearStatus(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "pubBlossom":
+		if err := pubBlossom(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "blossomUpload":
+		if err := blossomUpload(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "blossomList":
+		if err := blossomList(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "blossomDelete":
+		if err := blossomDelete(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	case "blossomMirror":
+		if err := blossomMirror(os.Args, cc); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 	case "removeEvent":
 		if err := removeEvent(os.Args, cc); err != nil {
 			log.Fatal(err)
@@ -181,6 +406,7 @@ func main() {
 		log.Fatal(errors.New("Subcommand does not exist."))
 		os.Exit(1)
 	}
+	cc.verifier.report(os.Stderr)
 }
 
 // }}}
@@ -220,6 +446,14 @@ func initEnv(cc confClass) error {
 		`{
 	"short code" : "image url"
 }
+`); err != nil {
+		return err
+	}
+	// make skeleton of Blossom server list
+	if err := cc.create(cc.ConfData.Filename.Blossom,
+		`[
+	"https://"
+]
 `); err != nil {
 		return err
 	}
@@ -329,20 +563,11 @@ func publishProfile(cc confClass) error {
 	// calling Sign sets the event ID field and the event Sig field
 	ev.Sign(sk)
 
-	// publish the event to two relays
-	ctx := context.Background()
-	for _, url := range rl {
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		err = relay.Publish(ctx, ev)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		fmt.Printf("published to %s\n", url)
+	// publish the event to the write relays; failed relays are kept in the outbox
+	p := newPublisher(cc)
+	defer p.close()
+	if _, err := deliverEvent(p, ev, rl, cc); err != nil {
+		return err
 	}
 	return nil
 }
@@ -405,20 +630,11 @@ func publishRelayList(cc confClass) error {
 	// calling Sign sets the event ID field and the event Sig field
 	ev.Sign(sk)
 
-	// publish the event to two relays
-	ctx := context.Background()
-	for _, url := range rl {
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		err = relay.Publish(ctx, ev)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		fmt.Printf("published relay list to %s\n", url)
+	// publish the event to the write relays; failed relays are kept in the outbox
+	pub := newPublisher(cc)
+	defer pub.close()
+	if _, err := deliverEvent(pub, ev, rl, cc); err != nil {
+		return err
 	}
 
 	return nil
@@ -430,23 +646,29 @@ func publishRelayList(cc confClass) error {
 readStdIn {{{
 */
 func readStdIn() (string, error) {
-	cn := make(chan string, 1)
-	go func() {
-		sc := bufio.NewScanner(os.Stdin)
-		var buff bytes.Buffer
-		for sc.Scan() {
-			fmt.Fprintln(&buff, sc.Text())
-		}
-		cn <- buff.String()
-	}()
-	timer := time.NewTimer(time.Second)
-	defer timer.Stop()
-	select {
-	case text := <-cn:
-		return strings.TrimRight(text, "\n\r"), nil
-	case <-timer.C:
-		return "", errors.New("Time out input from standard input")
+	// Nothing is piped in when stdin is a terminal.
+	// Waiting there would block until the user types EOF.
+	if isTerminal(os.Stdin) {
+		return "", errors.New("No input from standard input")
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n\r"), nil
+}
+
+// }}}
+
+/*
+isTerminal {{{
+*/
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	if err != nil {
+		return false
 	}
+	return st.Mode()&os.ModeCharDevice != 0
 }
 
 // }}}
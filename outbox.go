@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	outboxDir           = "outbox"
+	outboxExt           = ".json"
+	defaultOutboxExpiry = "24h"
+	outboxBaseBackoff   = time.Minute
+	outboxMaxBackoff    = time.Hour
+)
+
+/*
+outbox structure {{{
+
+A signed event that some relay may still accept is kept with the
+delivery status of each relay, so that "outbox flush" can retry it.
+*/
+type OutboxRelay struct {
+	Relay       string          `json:"relay"`
+	Ok          bool            `json:"ok"`
+	Final       bool            `json:"final,omitempty"`
+	Message     string          `json:"message,omitempty"`
+	Attempts    int             `json:"attempts"`
+	LastAttempt nostr.Timestamp `json:"last_attempt"`
+	NextAttempt nostr.Timestamp `json:"next_attempt,omitempty"`
+}
+type OutboxEntry struct {
+	Event     nostr.Event     `json:"event"`
+	ExpiresAt nostr.Timestamp `json:"expires_at"`
+	Relays    []OutboxRelay   `json:"relays"`
+}
+
+// }}}
+
+/* outboxBackoff {{{
+
+WHAT'S THIS?
+Returns how long to wait before the next try after attempts failures.
+The wait doubles from one minute up to one hour.
+*/
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseBackoff
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if outboxMaxBackoff < d {
+		d = outboxMaxBackoff
+	}
+	return d
+}
+
+// }}}
+
+/* OutboxEntry.update {{{
+
+WHAT'S THIS?
+Records the results of one delivery try.
+*/
+func (r *OutboxEntry) update(results []PublishResult, now time.Time) {
+	for _, res := range results {
+		i := -1
+		for j := range r.Relays {
+			if r.Relays[j].Relay == res.Relay {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			r.Relays = append(r.Relays, OutboxRelay{Relay: res.Relay})
+			i = len(r.Relays) - 1
+		}
+		rl := &r.Relays[i]
+		rl.Attempts++
+		rl.LastAttempt = nostr.Timestamp(now.Unix())
+		rl.Ok = res.Ok
+		rl.Message = res.Message
+		rl.Final = !res.Ok && !res.retryable()
+		rl.NextAttempt = 0
+		if !res.Ok && !rl.Final {
+			rl.NextAttempt = nostr.Timestamp(now.Add(outboxBackoff(rl.Attempts)).Unix())
+		}
+	}
+}
+
+// pending returns the relays to retry at now.
+func (r OutboxEntry) pending(now time.Time) []string {
+	rl := []string{}
+	for _, v := range r.Relays {
+		if !v.Ok && !v.Final && v.NextAttempt <= nostr.Timestamp(now.Unix()) {
+			rl = append(rl, v.Relay)
+		}
+	}
+	return rl
+}
+
+// delivered reports whether every relay accepted the event.
+func (r OutboxEntry) delivered() bool {
+	for _, v := range r.Relays {
+		if !v.Ok {
+			return false
+		}
+	}
+	return true
+}
+
+// finished reports whether no relay is left to retry.
+func (r OutboxEntry) finished() bool {
+	for _, v := range r.Relays {
+		if !v.Ok && !v.Final {
+			return false
+		}
+	}
+	return true
+}
+
+// }}}
+
+/* outbox file helpers {{{
+ */
+func (cc *confClass) outboxPath(id string) (string, error) {
+	d, err := cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	d = filepath.Join(d, outboxDir)
+	if err := os.MkdirAll(d, 0700); err != nil {
+		return "", err
+	}
+	if id == "" {
+		return d, nil
+	}
+	if !is64HexString(id) {
+		return "", fmt.Errorf("Invalid event id %q", id)
+	}
+	return filepath.Join(d, id+outboxExt), nil
+}
+func (cc *confClass) saveOutboxEntry(e OutboxEntry) error {
+	path, err := cc.outboxPath(e.Event.ID)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(e, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+func (cc *confClass) loadOutbox() ([]OutboxEntry, error) {
+	d, err := cc.outboxPath("")
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(d, "*"+outboxExt))
+	if err != nil {
+		return nil, err
+	}
+	es := []OutboxEntry{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var e OutboxEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("%v: %v", filepath.Base(f), err)
+		}
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Event.CreatedAt < es[j].Event.CreatedAt
+	})
+	return es, nil
+}
+func (cc *confClass) removeOutboxEntry(id string) error {
+	path, err := cc.outboxPath(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// }}}
+
+/* deliverEvent {{{
+
+WHAT'S THIS?
+Publishes a signed event and prints the result of each relay.
+When a relay failed in a way worth retrying, the event is kept in the
+outbox for "outbox flush". Returns an error when fewer relays than minSuccess
+accepted the event.
+With --dry-run or --sign-only, ev is only printed.
+*/
+func deliverEvent(p *Publisher, ev nostr.Event, rl []string, cc confClass) ([]PublishResult, error) {
+	if !cc.publishes() {
+		return nil, printEvent(ev, cc.dryRun)
+	}
+	results := p.publish(ev, rl)
+	printPublishResults(ev.ID, results)
+
+	expiry, err := time.ParseDuration(cc.ConfData.Settings.OutboxExpiry)
+	if err != nil {
+		expiry, _ = time.ParseDuration(defaultOutboxExpiry)
+	}
+	now := time.Now()
+	e := OutboxEntry{
+		Event:     ev,
+		ExpiresAt: nostr.Timestamp(now.Add(expiry).Unix()),
+	}
+	e.update(results, now)
+	if !e.finished() {
+		if err := cc.saveOutboxEntry(e); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save the event to the outbox: %v\n", err)
+		}
+	}
+	return results, checkMinSuccess(results, cc.ConfData.Settings.MinSuccess)
+}
+
+// }}}
+
+/*
+	outbox {{{
+		[infomation for develop]
+		usage:
+			nostk outbox status
+			nostk outbox flush
+*/
+func outbox(args []string, cc confClass) error {
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	switch args[2] {
+	case "status":
+		return outboxStatus(cc)
+	case "flush":
+		return flushOutbox(cc)
+	}
+	return fmt.Errorf("Unknown outbox command %v", args[2])
+}
+
+// }}}
+
+/* outboxStatus {{{
+ */
+type OutboxInfo struct {
+	Id        string   `json:"id"`
+	Kind      int      `json:"kind"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at"`
+	Delivered []string `json:"delivered,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+}
+
+func outboxStatus(cc confClass) error {
+	es, err := cc.loadOutbox()
+	if err != nil {
+		return err
+	}
+	infos := []OutboxInfo{}
+	for _, e := range es {
+		info := OutboxInfo{
+			Id:        e.Event.ID,
+			Kind:      e.Event.Kind,
+			CreatedAt: e.Event.CreatedAt.Time().Format(layout),
+			ExpiresAt: e.ExpiresAt.Time().Format(layout),
+		}
+		for _, r := range e.Relays {
+			if r.Ok {
+				info.Delivered = append(info.Delivered, r.Relay)
+				continue
+			}
+			next := "no retry"
+			if !r.Final {
+				next = "next " + r.NextAttempt.Time().Format(layout)
+			}
+			s := fmt.Sprintf("%v (%d attempts, %v): %v", r.Relay, r.Attempts, next, r.Message)
+			info.Failed = append(info.Failed, s)
+		}
+		infos = append(infos, info)
+	}
+	return printJson(infos)
+}
+
+// }}}
+
+/* flushOutbox {{{
+
+WHAT'S THIS?
+Retries the relays whose backoff has passed.
+Entries with no relay left to retry or past their expiry are removed,
+as are events whose NIP-40 expiration has passed.
+Meant to be run from cron like "queue run".
+*/
+func flushOutbox(cc confClass) error {
+	if !cc.publishes() {
+		return errPublishMode
+	}
+	es, err := cc.loadOutbox()
+	if err != nil {
+		return err
+	}
+	p := newPublisher(cc)
+	defer p.close()
+	now := time.Now()
+	for _, e := range es {
+		expired := e.ExpiresAt < nostr.Timestamp(now.Unix()) ||
+			isExpired(e.Event.Tags, nostr.Timestamp(now.Unix()))
+		if expired || e.finished() {
+			if err := cc.dropOutboxEntry(e); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rl := e.pending(now)
+		if len(rl) < 1 {
+			continue
+		}
+		results := p.publish(e.Event, rl)
+		printPublishResults(e.Event.ID, results)
+		e.update(results, time.Now())
+		if e.finished() {
+			err = cc.dropOutboxEntry(e)
+		} else {
+			err = cc.saveOutboxEntry(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropOutboxEntry removes e, printing the relays that never accepted it.
+func (cc *confClass) dropOutboxEntry(e OutboxEntry) error {
+	failed := []string{}
+	for _, r := range e.Relays {
+		if !r.Ok {
+			failed = append(failed, r.Relay)
+		}
+	}
+	if 0 < len(failed) {
+		fmt.Printf("%v: gave up on %v\n", e.Event.ID, strings.Join(failed, ", "))
+	}
+	return cc.removeOutboxEntry(e.Event.ID)
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := outboxBackoff(attempts); got != want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestOutboxEntryUpdate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	e := OutboxEntry{}
+	e.update([]PublishResult{
+		{Relay: "wss://a", Ok: true, Status: resultAccepted},
+		{Relay: "wss://b", Status: resultFailed, Message: "connection refused"},
+		{Relay: "wss://c", Status: resultRejected, Reason: "blocked", Message: "blocked: banned"},
+	}, now)
+	if e.delivered() {
+		t.Fatal("delivered with a failed relay")
+	}
+	if got := e.pending(now); len(got) != 0 {
+		t.Errorf("pending before backoff = %v", got)
+	}
+	later := now.Add(time.Minute)
+	if got := e.pending(later); !reflect.DeepEqual(got, []string{"wss://b"}) {
+		t.Errorf("pending after backoff = %v", got)
+	}
+
+	e.update([]PublishResult{{Relay: "wss://b", Status: resultFailed, Message: "timeout"}}, later)
+	if b := e.Relays[1]; b.Attempts != 2 || b.NextAttempt != nostr.Timestamp(later.Add(2*time.Minute).Unix()) {
+		t.Errorf("second failure = %+v", b)
+	}
+	e.update([]PublishResult{{Relay: "wss://b", Ok: true, Status: resultAccepted}}, later.Add(2*time.Minute))
+	if e.delivered() || !e.finished() {
+		t.Errorf("blocked relay should finish without delivery: %+v", e.Relays)
+	}
+}
+
+func TestOutboxStore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cc := confClass{}
+	id := "a3c0e069adeb19d8f59ec4e6b5157b7d3416c08805f9bd4849049325747a8086"
+	e := OutboxEntry{Event: nostr.Event{ID: id, Kind: 1}, Relays: []OutboxRelay{{Relay: "wss://a"}}}
+	if err := cc.saveOutboxEntry(e); err != nil {
+		t.Fatal(err)
+	}
+	es, err := cc.loadOutbox()
+	if err != nil || len(es) != 1 || es[0].Event.ID != id {
+		t.Fatalf("loadOutbox = %+v, %v", es, err)
+	}
+	if err := cc.removeOutboxEntry(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cc.outboxPath("../x"); err == nil {
+		t.Error("outboxPath should accept only event ids")
+	}
+}
+
+func TestDeliverEventKeepsOnlyPending(t *testing.T) {
+	accept := newTestRelay(t, &testRelay{})
+	block := newTestRelay(t, &testRelay{Reply: func(ev nostr.Event) (bool, string) {
+		return false, "blocked: not allowed"
+	}})
+	hang := newTestRelay(t, &testRelay{NoOK: true})
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.OkTimeout = "500ms"
+	p := newPublisher(cc)
+	defer p.close()
+
+	// nothing to retry: accepted or refused for good
+	done := signedEvent(t, "done")
+	if _, err := deliverEvent(p, done, []string{accept.URL, block.URL}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if es, _ := cc.loadOutbox(); len(es) != 0 {
+		t.Errorf("outbox = %+v", es)
+	}
+
+	pending := signedEvent(t, "pending")
+	if _, err := deliverEvent(p, pending, []string{accept.URL, hang.URL}, cc); err != nil {
+		t.Fatal(err)
+	}
+	es, _ := cc.loadOutbox()
+	if len(es) != 1 || es[0].Event.ID != pending.ID {
+		t.Fatalf("outbox = %+v", es)
+	}
+
+	// the entry is removed once the last relay accepts it
+	back := newTestRelay(t, &testRelay{})
+	e := es[0]
+	for i := range e.Relays {
+		if e.Relays[i].Relay == hang.URL {
+			e.Relays[i].Relay = back.URL
+			e.Relays[i].NextAttempt = 0
+		}
+	}
+	cc.saveOutboxEntry(e)
+	if err := flushOutbox(cc); err != nil {
+		t.Fatal(err)
+	}
+	if es, _ := cc.loadOutbox(); len(es) != 0 {
+		t.Errorf("outbox after flush = %+v", es)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	//"log"
+)
+
+/* loadPinList {{{
+
+WHAT'S THIS?
+Reads the newest kind 10001 event of pk from the read relays and
+returns all its tags in list order, so that the tags nostk does not
+edit ("a" articles, "t" ...) are published back as is.
+An empty list is returned when nothing has been published yet; see
+fetchReplaceableList for force.
+*/
+func loadPinList(pk string, cc confClass, force bool) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	ev, err := fetchReplaceableList(cc, nostr.KindPinList, pk, force)
+	if err != nil || ev == nil {
+		return tgs, err
+	}
+	for _, tg := range ev.Tags {
+		if 0 < len(tg) {
+			tgs = append(tgs, tg)
+		}
+	}
+	return tgs, nil
+}
+
+// }}}
+
+/* publishPinList {{{
+ */
+func publishPinList(tgs nostr.Tags, cc confClass) error {
+	dataRawArg := RawArg{
+		Kind:    nostr.KindPinList,
+		Content: "",
+		Tags:    tgs,
+	}
+	return publishRawArg(dataRawArg, keptTags, cc)
+}
+
+// }}}
+
+/*
+	pin {{{
+		[infomation for develop]
+		usage:
+			nostk pin <event_id> [--force]
+		kind: 10001
+		content: ""
+		tags [
+			"e": event id (hex)
+		]
+*/
+func pin(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	eventId, err := toHexId(args[2], "note", "nevent")
+	if err != nil {
+		return err
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadPinList(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	tg := nostr.Tag{"e", eventId}
+	if 0 <= indexOfTag(tgs, tg) {
+		return fmt.Errorf("%v is already pinned", args[2])
+	}
+	tgs = append(tgs, tg)
+	return publishPinList(tgs, cc)
+}
+
+// }}}
+
+/*
+	unpin {{{
+		[infomation for develop]
+		usage:
+			nostk unpin <event_id> [--force]
+*/
+func unpin(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	eventId, err := toHexId(args[2], "note", "nevent")
+	if err != nil {
+		return err
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadPinList(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	tgs, removed := removeTag(tgs, nostr.Tag{"e", eventId})
+	if removed == false {
+		return fmt.Errorf("%v is not pinned", args[2])
+	}
+	return publishPinList(tgs, cc)
+}
+
+// }}}
+
+/*
+	catPins {{{
+		[infomation for develop]
+		usage:
+			nostk catPins [npub]
+		Displays the pinned notes themselves in the order of the pin list.
+*/
+func catPins(args []string, cc confClass) error {
+	var pk string
+	var err error
+	switch len(args) {
+	case 2:
+		if pk, err = cc.getMySelfHexPubkey(); err != nil {
+			return err
+		}
+	case 3:
+		if pk, err = toHexId(args[2], "npub", "nprofile"); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+
+	// nothing is published here, so no answer is no pins
+	tgs, err := loadPinList(pk, cc, true)
+	if err != nil {
+		return err
+	}
+	ids := []string{}
+	for _, tg := range tgs {
+		if 1 < len(tg) && tg[indexTagName] == "e" {
+			ids = append(ids, tg[1])
+		}
+	}
+
+	mf := loadMuteFilter(cc)
+
+	notes := []Recieve{}
+	if 0 < len(ids) {
+		evs, err := fetchRelayEvents(cc, nostr.Filters{{IDs: ids}}, len(ids))
+		if err != nil {
+			return err
+		}
+		byId := make(map[string]Recieve)
+		for _, ev := range evs {
+			if mf.isMuted(ev.Event) {
+				continue
+			}
+			byId[ev.ID] = convertRelayEventToRecieve(&ev)
+		}
+		reb := replaceEnginForBech32{}
+		for _, id := range ids {
+			data, ok := byId[id]
+			if !ok {
+				continue
+			}
+			if tmp, err := reb.replaceToBech32(data); err != nil {
+				return err
+			} else {
+				notes = append(notes, tmp)
+			}
+		}
+	}
+	if data, err := json5.Marshal(notes); err != nil {
+		return err
+	} else {
+		fmt.Printf("%v", string(data))
+	}
+	return nil
+}
+
+// }}}
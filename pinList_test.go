@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPinKeepsTags(t *testing.T) {
+	const (
+		pinned  = "a3c0e069adeb19d8f59ec4e6b5157b7d3416c08805f9bd4849049325747a8086"
+		another = "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"
+	)
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc := setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+	cc.ConfData.Settings.MinSuccess = 1
+	if err := pin([]string{"nostk", "pin", pinned}, cc); err == nil {
+		t.Error("pinned without reading the list")
+	}
+
+	sk, _ := cc.load(cc.ConfData.Filename.Hsec)
+	pk, _ := nostr.GetPublicKey(sk)
+	list := nostr.Event{
+		Kind:      nostr.KindPinList,
+		CreatedAt: nostr.Now() - 10,
+		Tags:      nostr.Tags{{"e", pinned}, {"a", "30023:" + pk + ":article"}},
+	}
+	list.Sign(sk)
+	r := newTestRelay(t, &testRelay{Stored: []nostr.Event{list}})
+	cc = setupTestEnv(t, map[string]RwFlag{r.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.MinSuccess = 1
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, ".hsec"), []byte(sk), 0600)
+
+	if err := pin([]string{"nostk", "pin", another}, cc); err != nil {
+		t.Fatal(err)
+	}
+	tgs, err := loadPinList(pk, cc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 3 || tgs[1][indexTagName] != "a" || tgs[2][1] != another {
+		t.Errorf("pin list = %v", tgs)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"math/bits"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	powOption        = "--pow"
+	maxPowDifficulty = 64
+	powBatch         = 10000
+	powProgressTick  = 500 * time.Millisecond
+)
+
+var errPowCanceled = errors.New("PoW mining canceled")
+
+/* setPowOption {{{
+
+WHAT'S THIS?
+Takes --pow out of the arguments of any subcommand
+and overrides "powDifficulty" of config.json.
+*/
+func (cc *confClass) setPowOption(args []string) ([]string, error) {
+	args, v, ok, err := extractOption(args, powOption)
+	if err != nil || !ok {
+		return args, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || maxPowDifficulty < n {
+		return args, fmt.Errorf("--pow must be a difficulty from 0 to %d", maxPowDifficulty)
+	}
+	cc.ConfData.Settings.PowDifficulty = n
+	return args, nil
+}
+
+// }}}
+
+/* powDifficulty {{{
+
+WHAT'S THIS?
+Returns the difficulty to mine for publishing to rl: "powDifficulty"
+(or --pow), raised to the highest NIP-11 min_pow_difficulty of rl.
+relay is the relay that raised it, or "".
+*/
+func (cc confClass) powDifficulty(rl []string) (difficulty int, relay string) {
+	difficulty = cc.ConfData.Settings.PowDifficulty
+	c := newRelayInfoCache(cc, parseDurationOr(cc.ConfData.Settings.ConnectTimeout, defaultConnectTimeout))
+	mins := make([]int, len(rl))
+	var wg sync.WaitGroup
+	for i, url := range rl {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			if lim := c.get(url, false).limitation(); lim != nil {
+				mins[i] = lim.MinPowDifficulty
+			}
+		}(i, url)
+	}
+	wg.Wait()
+	c.flush()
+	for i, m := range mins {
+		if difficulty < m && m <= maxPowDifficulty {
+			difficulty, relay = m, rl[i]
+		}
+	}
+	return difficulty, relay
+}
+
+// powTarget is powDifficulty that tells which relay raised it.
+func (cc confClass) powTarget(rl []string) int {
+	d, relay := cc.powDifficulty(rl)
+	if relay != "" {
+		fmt.Printf("PoW difficulty %d is required by %v\n", d, relay)
+	}
+	return d
+}
+
+// }}}
+
+/* minePow {{{
+
+WHAT'S THIS?
+Adds a NIP-13 nonce tag to ev so that the id has at least difficulty
+leading zero bits. Works on every CPU core until found or ctx is done.
+ev.PubKey and ev.CreatedAt must be set; ev must be signed afterwards.
+Progress is shown on stderr when it is a terminal.
+*/
+func minePow(ctx context.Context, ev *nostr.Event, difficulty int) error {
+	if ev.PubKey == "" {
+		return errors.New("Public key is needed before mining PoW")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var hashes atomic.Uint64
+	found := make(chan nostr.Tag, 1)
+	n := runtime.NumCPU()
+	for i := 0; i < n; i++ {
+		e := *ev
+		e.Tags = append(nostr.Tags{}, ev.Tags...)
+		go func(e nostr.Event, nonce uint64) {
+			tag := nostr.Tag{"nonce", "", strconv.Itoa(difficulty)}
+			e.Tags = append(e.Tags, tag)
+			for {
+				for j := 0; j < powBatch; j++ {
+					tag[1] = strconv.FormatUint(nonce, 10)
+					if difficulty <= leadingZeroBits(sha256.Sum256(e.Serialize())) {
+						select {
+						case found <- tag:
+						default:
+						}
+						cancel()
+						return
+					}
+					nonce += uint64(n)
+				}
+				hashes.Add(powBatch)
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}(e, uint64(i))
+	}
+
+	progress := isTerminal(os.Stderr)
+	start := time.Now()
+	ticker := time.NewTicker(powProgressTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case tag := <-found:
+			if progress {
+				fmt.Fprintln(os.Stderr)
+			}
+			ev.Tags = append(ev.Tags, tag)
+			return nil
+		case <-ctx.Done():
+			// a worker may have found the nonce and canceled
+			select {
+			case tag := <-found:
+				if progress {
+					fmt.Fprintln(os.Stderr)
+				}
+				ev.Tags = append(ev.Tags, tag)
+				return nil
+			default:
+			}
+			if progress {
+				fmt.Fprintln(os.Stderr)
+			}
+			return errPowCanceled
+		case <-ticker.C:
+			if progress {
+				h := hashes.Load()
+				fmt.Fprintf(os.Stderr, "\rMining PoW difficulty %d on %d cores: %d hashes (%.0f/s)",
+					difficulty, n, h, float64(h)/time.Since(start).Seconds())
+			}
+		}
+	}
+}
+
+// leadingZeroBits counts the leading zero bits of an event id.
+func leadingZeroBits(h [32]byte) int {
+	n := 0
+	for _, b := range h {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip13"
+	"testing"
+)
+
+func TestMinePow(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	ev := nostr.Event{PubKey: pk, Kind: 1, CreatedAt: nostr.Now(), Content: "pow", Tags: nostr.Tags{{"t", "pow"}}}
+	if err := minePow(context.Background(), &ev, 12); err != nil {
+		t.Fatal(err)
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	if d := nip13.Difficulty(ev.ID); d < 12 {
+		t.Errorf("difficulty = %d", d)
+	}
+	if tag := ev.Tags.Find("nonce"); tag == nil || tag[2] != "12" {
+		t.Errorf("nonce tag = %v", tag)
+	}
+	if len(ev.Tags) != 2 {
+		t.Errorf("tags = %v", ev.Tags)
+	}
+	if err := checkTags(1, ev.Tags); err != nil {
+		t.Errorf("checkTags: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ev2 := nostr.Event{PubKey: pk, Kind: 1, CreatedAt: nostr.Now()}
+	if err := minePow(ctx, &ev2, maxPowDifficulty); err != errPowCanceled {
+		t.Errorf("canceled mining = %v", err)
+	}
+	if ev2.Tags.Find("nonce") != nil {
+		t.Error("canceled mining added a nonce")
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	var h [32]byte
+	if n := leadingZeroBits(h); n != 256 {
+		t.Errorf("zero = %d", n)
+	}
+	h[1] = 0x10
+	if n := leadingZeroBits(h); n != 11 {
+		t.Errorf("0x0010 = %d", n)
+	}
+}
+
+func TestSetPowOption(t *testing.T) {
+	cc := confClass{}
+	args, err := cc.setPowOption([]string{"nostk", "pubMessage", "--pow", "16", "hello"})
+	if err != nil || len(args) != 3 || cc.ConfData.Settings.PowDifficulty != 16 {
+		t.Errorf("setPowOption = %v, %v, %d", args, err, cc.ConfData.Settings.PowDifficulty)
+	}
+	for _, v := range []string{"x", "-1", "65"} {
+		if _, err := cc.setPowOption([]string{"nostk", "pubRaw", "--pow", v}); err == nil {
+			t.Errorf("--pow %v: no error", v)
+		}
+	}
+}
+
+func TestPowDifficulty(t *testing.T) {
+	url := newTestRelay(t, &testRelay{Limits: &nip11.RelayLimitationDocument{MinPowDifficulty: 12}}).URL
+
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.ConnectTimeout = "1s"
+	cc.ConfData.Settings.PowDifficulty = 4
+	if d, relay := cc.powDifficulty([]string{url}); d != 12 || relay != url {
+		t.Errorf("powDifficulty = %d, %v", d, relay)
+	}
+	cc.ConfData.Settings.PowDifficulty = 20
+	if d, relay := cc.powDifficulty([]string{url}); d != 20 || relay != "" {
+		t.Errorf("powDifficulty = %d, %v", d, relay)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	//"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	titleOption = "--title"
+	urlOption   = "--url"
+)
+
+/*
+image information structure {{{
+*/
+type ImageInfo struct {
+	Path     string
+	Mime     string
+	Width    int
+	Height   int
+	Sha256   string
+	Blurhash string
+	Url      string
+}
+
+// }}}
+
+/* readImageInfo {{{
+
+WHAT'S THIS?
+Reads a local image and collects what NIP-92 imeta needs.
+PNG, JPEG, GIF and WebP are decoded for the blurhash.
+golang.org/x/image/webp does not decode animated WebP, so only its
+size is read from the header, without a blurhash.
+*/
+func readImageInfo(path string) (ImageInfo, error) {
+	info := ImageInfo{Path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	sum := sha256.Sum256(b)
+	info.Sha256 = hex.EncodeToString(sum[:])
+	info.Mime = http.DetectContentType(b)
+
+	switch info.Mime {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil && info.Mime == "image/webp" {
+			fmt.Fprintf(os.Stderr, "No blurhash for %v: %v\n", path, err)
+			info.Width, info.Height, err = webpSize(b)
+			return info, err
+		} else if err != nil {
+			return info, err
+		}
+		info.Width = img.Bounds().Dx()
+		info.Height = img.Bounds().Dy()
+		if info.Blurhash, err = encodeBlurhash(img); err != nil {
+			return info, err
+		}
+	default:
+		return info, fmt.Errorf("Not supported image type %v : %v", info.Mime, path)
+	}
+	return info, nil
+}
+
+// }}}
+
+/* webpSize {{{
+
+WHAT'S THIS?
+Reads the canvas size from the WebP header (VP8, VP8L and VP8X).
+*/
+func webpSize(b []byte) (int, int, error) {
+	if len(b) < 30 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return 0, 0, errors.New("Invalid WebP header")
+	}
+	le24 := func(p []byte) int {
+		return int(p[0]) | int(p[1])<<8 | int(p[2])<<16
+	}
+	switch string(b[12:16]) {
+	case "VP8X":
+		return 1 + le24(b[24:27]), 1 + le24(b[27:30]), nil
+	case "VP8 ":
+		if b[23] != 0x9d || b[24] != 0x01 || b[25] != 0x2a {
+			return 0, 0, errors.New("Invalid WebP VP8 frame")
+		}
+		w := int(binary.LittleEndian.Uint16(b[26:28]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(b[28:30]) & 0x3fff)
+		return w, h, nil
+	case "VP8L":
+		if b[20] != 0x2f {
+			return 0, 0, errors.New("Invalid WebP VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(b[21:25])
+		return 1 + int(bits&0x3fff), 1 + int((bits>>14)&0x3fff), nil
+	}
+	return 0, 0, errors.New("Not supported WebP format")
+}
+
+// }}}
+
+/* ImageInfo.imetaTag {{{
+ */
+func (r ImageInfo) imetaTag() nostr.Tag {
+	tg := nostr.Tag{"imeta", "url " + r.Url, "m " + r.Mime}
+	if 0 < r.Width && 0 < r.Height {
+		tg = append(tg, fmt.Sprintf("dim %dx%d", r.Width, r.Height))
+	}
+	tg = append(tg, "x "+r.Sha256)
+	if 0 < len(r.Blurhash) {
+		tg = append(tg, "blurhash "+r.Blurhash)
+	}
+	return tg
+}
+
+// }}}
+
+/* Uploader {{{
+
+WHAT'S THIS?
+Puts a local file on a media server and returns its URL.
+The uploader is chosen by "uploader" in the settings of config.json.
+*/
+type Uploader interface {
+	upload(info ImageInfo) (string, error)
+}
+
+type UploaderTbl map[string]func(cc confClass) (Uploader, error)
+
+func NewUploaderTbl() UploaderTbl {
+	return UploaderTbl{
+		"blossom": newBlossomUploader,
+		"command": newCommandUploader,
+	}
+}
+func (r UploaderTbl) get(cc confClass) (Uploader, error) {
+	name := cc.ConfData.Settings.Uploader
+	if name == "" {
+		return nil, errors.New("Not set uploader in config.json. Use --url option.")
+	}
+	f, exists := r[name]
+	if !exists {
+		return nil, fmt.Errorf("Not supported uploader %v", name)
+	}
+	return f(cc)
+}
+
+// staticUploader returns URLs given by --url options in order.
+type staticUploader struct {
+	urls []string
+	next int
+}
+
+func (r *staticUploader) upload(info ImageInfo) (string, error) {
+	if len(r.urls) <= r.next {
+		return "", fmt.Errorf("Not set URL for %v", info.Path)
+	}
+	url := r.urls[r.next]
+	r.next++
+	return url, nil
+}
+
+// commandUploader runs "uploadCommand" of config.json with the file
+// path as its argument and uses the first line of stdout as the URL.
+type commandUploader struct {
+	command string
+}
+
+func newCommandUploader(cc confClass) (Uploader, error) {
+	c := cc.ConfData.Settings.UploadCommand
+	if c == "" {
+		return nil, errors.New("Not set uploadCommand in config.json")
+	}
+	return &commandUploader{command: c}, nil
+}
+func (r *commandUploader) upload(info ImageInfo) (string, error) {
+	c := exec.Command(r.command, info.Path)
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", err
+	}
+	url := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if len(url) < 1 {
+		return "", fmt.Errorf("%v returned no URL", r.command)
+	}
+	return url, nil
+}
+
+// }}}
+
+/*
+	pubPicture {{{
+		[infomation for develop]
+		usage:
+			nostk pubPicture <file...> --title <title> [--url url...] [text]
+		kind: 20
+		content: text
+		tags [
+			"title": title
+			"imeta": url, m, dim, x, blurhash (per image)
+			"m": mime type
+			"x": sha256 of the file
+		]
+*/
+func pubPicture(args []string, cc confClass) error {
+	args, title, hasTitle, err := extractOption(args, titleOption)
+	if err != nil {
+		return err
+	}
+	if !hasTitle {
+		return errors.New("Not set title. Use --title option.")
+	}
+	args, urls, err := extractOptions(args, urlOption)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+
+	files := args[2:]
+	content := ""
+	if _, err := os.Stat(files[len(files)-1]); err != nil && 1 < len(files) {
+		content = files[len(files)-1]
+		files = files[:len(files)-1]
+	}
+
+	var uploader Uploader
+	if 0 < len(urls) {
+		if len(urls) != len(files) {
+			return errors.New("The number of --url options does not match the number of files")
+		}
+		uploader = &staticUploader{urls: urls}
+	} else if uploader, err = NewUploaderTbl().get(cc); err != nil {
+		return err
+	}
+
+	tgs := nostr.Tags{{"title", title}}
+	mimes := nostr.Tags{}
+	hashes := nostr.Tags{}
+	for _, f := range files {
+		info, err := readImageInfo(f)
+		if err != nil {
+			return err
+		}
+		if info.Url, err = uploader.upload(info); err != nil {
+			return err
+		}
+		tgs = append(tgs, info.imetaTag())
+		if indexOfTag(mimes, nostr.Tag{"m", info.Mime}) < 0 {
+			mimes = append(mimes, nostr.Tag{"m", info.Mime})
+		}
+		hashes = append(hashes, nostr.Tag{"x", info.Sha256})
+	}
+	tgs = append(tgs, mimes...)
+	tgs = append(tgs, hashes...)
+
+	dataRawArg := RawArg{
+		Kind:    20,
+		Content: content,
+		Tags:    tgs,
+	}
+	return publishRawArg(dataRawArg, checkedTags, cc)
+}
+
+// }}}
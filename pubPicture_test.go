@@ -0,0 +1,104 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadImageInfo(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 16), 128, 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "test.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	info, err := readImageInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mime != "image/png" || info.Width != 32 || info.Height != 16 || len(info.Sha256) != 64 {
+		t.Fatalf("got info: %+v", info)
+	}
+	// 1 (size) + 1 (maximum value) + 4 (DC) + 2 * 11 (AC)
+	if len(info.Blurhash) != 28 {
+		t.Fatalf("got blurhash: %v", info.Blurhash)
+	}
+
+	info.Url = "https://example.com/test.png"
+	tg := info.imetaTag()
+	if tg[0] != "imeta" || tg[1] != "url https://example.com/test.png" || !strings.HasPrefix(tg[3], "dim 32x16") {
+		t.Fatalf("got imeta: %v", tg)
+	}
+}
+
+func TestEncodeBlurhashSolidColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	hash, err := encodeBlurhash(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// size flag "L" (4x3 components) and white DC (0xffffff in base83)
+	if hash[0:1] != "L" || hash[2:6] != "TSUA" {
+		t.Fatalf("got blurhash: %v", hash)
+	}
+}
+
+func TestWebpSize(t *testing.T) {
+	b := make([]byte, 30)
+	copy(b[0:], "RIFF")
+	copy(b[8:], "WEBPVP8X")
+	// canvas 640x480 (stored minus one, 24-bit little endian)
+	b[24], b[25], b[26] = 0x7f, 0x02, 0x00
+	b[27], b[28], b[29] = 0xdf, 0x01, 0x00
+	w, h, err := webpSize(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != 640 || h != 480 {
+		t.Fatalf("got size: %vx%v, Want size: 640x480", w, h)
+	}
+}
+
+func TestReadImageInfoWebp(t *testing.T) {
+	// 1x1 lossless WebP (VP8L)
+	b := []byte{
+		0x52, 0x49, 0x46, 0x46, 0x1a, 0x00, 0x00, 0x00, 0x57, 0x45, 0x42, 0x50,
+		0x56, 0x50, 0x38, 0x4c, 0x0d, 0x00, 0x00, 0x00, 0x2f, 0x00, 0x00, 0x00,
+		0x10, 0x07, 0x10, 0x11, 0x11, 0x88, 0x88, 0xfe, 0x07, 0x00,
+	}
+	path := filepath.Join(t.TempDir(), "test.webp")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := readImageInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mime != "image/webp" || info.Width != 1 || info.Height != 1 {
+		t.Fatalf("got info: %+v", info)
+	}
+	if len(info.Blurhash) != 28 {
+		t.Fatalf("got blurhash: %v", info.Blurhash)
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
 	"github.com/yosuke-furukawa/json5/encoding/json5"
+	"os"
+	"strings"
 )
 
 const (
@@ -12,22 +16,49 @@ const (
 )
 
 /* publishMessage {{{
- */
+
+WHAT'S THIS?
+Without text the message is read from standard input when it is piped,
+or composed in $EDITOR when it is a terminal.
+--cw and --reply fill in the content warning and the reply target then.
+*/
 func publishMessage(args []string, cc confClass) error {
-	dataRawArg := RawArg{}
-	tmpArgs := []string{
-		"nostk",
-		"pubMessage",
+	args, cw, _, err := extractOption(args, cwOption)
+	if err != nil {
+		return err
+	}
+	args, reply, _, err := extractOption(args, replyOption)
+	if err != nil {
+		return err
+	}
+	if 2 < len(args) && (0 < len(cw) || 0 < len(reply)) {
+		return errors.New("--cw and --reply are used only without text message")
 	}
+
+	dataRawArg := RawArg{}
 	switch len(args) {
 	case 1:
 		return errors.New("Not enough arguments")
 	case 2:
-		if tmpContent, err := readStdIn(); err != nil {
-			return errors.New("Not set text message")
-		} else {
-			dataRawArg.Kind = 1
-			dataRawArg.Content = tmpContent
+		d := Draft{Kind: 1, ContentWarning: cw, Reply: reply}
+		interactive := isTerminal(os.Stdin)
+		if interactive {
+			if d, err = composeInEditor(d); err != nil {
+				return err
+			}
+		} else if d.Content, err = readStdIn(); err != nil {
+			return fmt.Errorf("Not set text message: %w", err)
+		}
+		if dataRawArg, err = d.rawArg(cc); err != nil {
+			return err
+		}
+		if interactive {
+			if err := previewMessage(dataRawArg, cc); err != nil {
+				return err
+			}
+			if !confirm("Publish this message?") {
+				return errors.New("Canceled")
+			}
 		}
 	case 3, 4:
 		if tmpArgJson, err := buildJson(args); err != nil {
@@ -41,18 +72,100 @@ func publishMessage(args []string, cc confClass) error {
 	default:
 		return errors.New("Too meny argument")
 	}
-	if tmp, err := json5.Marshal(dataRawArg); err != nil {
+	if err := publishRawArg(dataRawArg, checkedTags, cc); err != nil {
 		return err
-	} else {
-		tmpArgs = append(tmpArgs, string(tmp))
 	}
-	if err := publishRaw(tmpArgs, cc); err != nil {
-		return err
+
+	return nil
+}
+
+// }}}
+
+/* composeInEditor {{{
+
+WHAT'S THIS?
+Opens $EDITOR on a temporary file in the draft format.
+The header shows the content warning and the reply target.
+*/
+func composeInEditor(d Draft) (Draft, error) {
+	e := os.Getenv("EDITOR")
+	if e == "" {
+		return d, errors.New("Not set EDITOR environment variables")
+	}
+	f, err := os.CreateTemp("", "nostk-*"+draftExt)
+	if err != nil {
+		return d, err
+	}
+	defer os.Remove(f.Name())
+
+	s, err := d.format()
+	if err != nil {
+		return d, err
+	}
+	s = strings.Replace(s, "---\n", "---\n# Write the message below the closing \"---\". An empty message aborts.\n", 1)
+	if _, err := f.WriteString(s); err != nil {
+		f.Close()
+		return d, err
+	}
+	if err := f.Close(); err != nil {
+		return d, err
 	}
+	if err := runEditor(e, f.Name()); err != nil {
+		return d, err
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return d, err
+	}
+	ret, err := parseDraft("", string(b))
+	if err != nil {
+		return d, err
+	}
+	if strings.TrimSpace(ret.Content) == "" {
+		return d, errors.New("Aborted because the message is empty")
+	}
+	return ret, nil
+}
 
+// }}}
+
+/* previewMessage {{{
+
+WHAT'S THIS?
+Shows the message with the tags that will be published,
+including hashtags and custom emoji detected from the content.
+*/
+func previewMessage(arg RawArg, cc confClass) error {
+	tgs, err := contentTags(arg.Kind, arg.Content, cc)
+	if err != nil {
+		return err
+	}
+	tgs = append(tgs, arg.Tags...)
+	fmt.Printf("kind: %d\n", arg.Kind)
+	for _, tg := range tgs {
+		fmt.Printf("%v: %v\n", tg[indexTagName], strings.Join(tg[1:], " "))
+	}
+	fmt.Println("----")
+	fmt.Println(arg.Content)
+	fmt.Println("----")
 	return nil
 }
 
 // }}}
 
+/* confirm {{{
+ */
+func confirm(prompt string) bool {
+	fmt.Printf("%v [y/N]: ", prompt)
+	ans, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	ans = strings.ToLower(strings.TrimSpace(ans))
+	return ans == "y" || ans == "yes"
+}
+
+// }}}
+
 // vim: set ts=2 sw=2 et:
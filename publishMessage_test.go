@@ -2,7 +2,10 @@ package main
 
 import (
 	"github.com/nbd-wtf/go-nostr"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSetHashTags(t *testing.T) {
@@ -110,3 +113,64 @@ func TestContainsNsec1(t *testing.T) {
 		}
 	}
 }
+
+func TestComposeInEditor(t *testing.T) {
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	// rewrites the whole file, as sed -i is not portable
+	script := "#!/bin/sh\nprintf '%s\\n' '---' 'kind: 1' 'content-warning: spoiler' 'reply: note1xyz' '---' 'hello #nostr' > \"$1\"\n"
+	if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", editor)
+
+	d, err := composeInEditor(Draft{Kind: 1, Reply: "note1xyz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Content != "hello #nostr" || d.ContentWarning != "spoiler" || d.Reply != "note1xyz" {
+		t.Errorf("composeInEditor = %+v", d)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.sh")
+	if err := os.WriteFile(empty, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", empty)
+	if _, err := composeInEditor(Draft{Kind: 1}); err == nil {
+		t.Error("expected error for empty message")
+	}
+}
+
+func TestReadStdIn(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	w.WriteString("hello\n")
+	w.Close()
+	if s, err := readStdIn(); err != nil || s != "hello" {
+		t.Errorf("readStdIn = %q, %v", s, err)
+	}
+
+	// a slow writer is read to the end
+	r2, w2, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	os.Stdin = r2
+	go func() {
+		defer w2.Close()
+		w2.WriteString("slow ")
+		time.Sleep(1500 * time.Millisecond)
+		w2.WriteString("writer\n")
+	}()
+	if s, err := readStdIn(); err != nil || s != "slow writer" {
+		t.Errorf("readStdIn = %q, %v", s, err)
+	}
+}
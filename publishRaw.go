@@ -8,18 +8,33 @@ import (
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/yosuke-furukawa/json5/encoding/json5"
 	//"log"
-	"runtime"
+	"os"
+	"os/signal"
 )
 
 const (
-	Main          = "main.main"
-	PubMessage    = "main.publishMessage"
-	PubMessageTo  = "main.publishMessageTo"
-	EmojiReaction = "main.emojiReaction"
 	lengthHexData = 64
 	indexTagName  = 0
 )
 
+/*
+tag policy {{{
+
+How mkEvent checks the tags of an event.
+	checkedTags: every tag must be allowed for the kind (NewChkTblMap)
+	keptTags:    the tags of a list read from relays are published back
+	             as they are, so that entries added by other clients are
+	             not lost
+*/
+type TagPolicy int
+
+const (
+	checkedTags TagPolicy = iota
+	keptTags
+)
+
+// }}}
+
 /*
 	 publishRaw {{{
 
@@ -42,75 +57,137 @@ func publishRaw(args []string, cc confClass) error {
 	var err error
 	var strjson string
 
-	pc, _, _, _ := runtime.Caller(1)
-	fn := runtime.FuncForPC(pc)
-	switch fn.Name() {
-	case Main:
-		switch len(args) {
-		case 2:
-			// Receive content from standard input
-			strjson, err = readStdIn()
-			if err != nil {
-				return errors.New("Not set json data")
-			}
-		case 3:
-			// Receive content from arguments
-			strjson = args[2]
-		default:
-			return errors.New("Invalid pubRaw subcommand argument")
+	switch len(args) {
+	case 2:
+		// Receive content from standard input
+		strjson, err = readStdIn()
+		if err != nil {
+			return errors.New("Not set json data")
 		}
-	case PubMessage, EmojiReaction:
+	case 3:
+		// Receive content from arguments
 		strjson = args[2]
 	default:
-		return errors.New("pubRaw function call from illegal function")
+		return errors.New("Invalid pubRaw subcommand argument")
 	}
+	return publishJSON(strjson, checkedTags, cc)
+}
 
-	var objJson interface{}
-	if err := json5.Unmarshal([]byte(strjson), &objJson); err != nil {
-		return err
-	}
+// }}}
+
+/* publishRawArg {{{
 
-	ev, err := mkEvent(objJson, cc)
+WHAT'S THIS?
+Publishes the event of dataRawArg. Used by the subcommands that build
+the event themselves, with the tag policy of the kind they publish.
+*/
+func publishRawArg(dataRawArg RawArg, policy TagPolicy, cc confClass) error {
+	tmp, err := json5.Marshal(dataRawArg)
 	if err != nil {
 		return err
 	}
+	return publishJSON(string(tmp), policy, cc)
+}
+
+// }}}
+
+/* publishJSON {{{
+
+WHAT'S THIS?
+Builds, signs and publishes the event of strjson, or only prints it
+with --dry-run and --sign-only.
+*/
+func publishJSON(strjson string, policy TagPolicy, cc confClass) error {
+	switch {
+	case cc.dryRun:
+		ev, err := mkRawEvent(strjson, policy, cc)
+		if err != nil {
+			return err
+		}
+		return printEvent(ev, true)
+	case cc.signOnly:
+		// no relays are asked for min_pow_difficulty
+		ev, err := mkSignedEvent(strjson, cc.ConfData.Settings.PowDifficulty, policy, cc)
+		if err != nil {
+			return err
+		}
+		return printEvent(ev, false)
+	}
 
 	var rl []string
 	if err := cc.getRelayList(&rl, writeFlag); err != nil {
 		return err
 	}
 
-	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	ev, err := mkSignedEvent(strjson, cc.powTarget(rl), policy, cc)
 	if err != nil {
-		fmt.Println("Nothing key pair. Make key pair.")
 		return err
 	}
 
-	ev.Sign(sk)
+	p := newPublisher(cc)
+	defer p.close()
+	if _, err := deliverEvent(p, ev, rl, cc); err != nil {
+		return err
+	}
 
-	ctx := context.Background()
-	for _, url := range rl {
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		err = relay.Publish(ctx, ev)
-		if err != nil {
-			fmt.Println(err)
-			continue
+	return nil
+}
+
+// }}}
+
+/* mkSignedEvent {{{
+
+WHAT'S THIS?
+Builds the event from the raw data with the mkEvent checks and signs it.
+When difficulty is not 0, a NIP-13 nonce is mined before signing.
+Ctrl-C cancels the mining.
+*/
+func mkSignedEvent(strjson string, difficulty int, policy TagPolicy, cc confClass) (nostr.Event, error) {
+	ev, err := mkRawEvent(strjson, policy, cc)
+	if err != nil {
+		return ev, err
+	}
+
+	sk, err := cc.load(cc.ConfData.Filename.Hsec)
+	if err != nil {
+		fmt.Println("Nothing key pair. Make key pair.")
+		return ev, err
+	}
+
+	if 0 < difficulty {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := minePow(ctx, &ev, difficulty); err != nil {
+			return ev, err
 		}
-		fmt.Printf("published to %s\n", url)
 	}
 
-	return nil
+	if err := ev.Sign(sk); err != nil {
+		return ev, err
+	}
+	return ev, nil
+}
+
+// }}}
+
+/* mkRawEvent {{{
+
+WHAT'S THIS?
+Builds the unsigned event from the raw data with the mkEvent checks.
+*/
+func mkRawEvent(strjson string, policy TagPolicy, cc confClass) (nostr.Event, error) {
+	var objJson interface{}
+	if err := json5.Unmarshal([]byte(strjson), &objJson); err != nil {
+		return nostr.Event{}, err
+	}
+	return mkEvent(objJson, policy, cc)
 }
 
 // }}}
 
 /* mkEvent {{{
  */
-func mkEvent(pJson interface{}, cc confClass) (nostr.Event, error) {
+func mkEvent(pJson interface{}, policy TagPolicy, cc confClass) (nostr.Event, error) {
 	var ev nostr.Event
 	kind, err := getKind(pJson)
 	if err != nil {
@@ -129,33 +206,33 @@ func mkEvent(pJson interface{}, cc confClass) (nostr.Event, error) {
   case 20:  // publish Picture-first feeds
 	case 10000: // publish mute list
 	case 10001: // publish Pinned notes
+	case 10007: // publish search relay list
+	case 10015: // publish interests (followed hashtags)
+	case 10030: // publish user emoji list
+	case 10063: // publish Blossom server list
+	case 30023: // publish long-form article
+	case 30024: // publish draft long-form article
 	case 30030: // publish custom emoji list
 	case 30315: // publish status
 	default:
 		return ev, errors.New("not yet suppoted kind")
 	}
 
-	tgs := nostr.Tags{}
-	// custom emojis
-	if err := cc.setCustomEmoji(content, &tgs); err != nil {
-		return ev, err
-	}
-
-	// hashtags
-	tmpstr, err := excludeHashtagsParsign(content)
+	tgs, err := contentTags(kind, content, cc)
 	if err != nil {
 		return ev, err
 	}
-	if err := setHashTags(tmpstr, &tgs); err != nil {
-    return ev, err;
-  }
-
 
 	addTagsFromJson(pJson, &tgs)
-
-	if err := checkTags(kind, tgs); err != nil {
+	if err := cc.addExpiration(kind, &tgs); err != nil {
 		return ev, err
 	}
+
+	if policy == checkedTags {
+		if err := checkTags(kind, tgs); err != nil {
+			return ev, err
+		}
+	}
 	tagsFuncs := Tags{}
 	if ret := tagsFuncs.hasPrefix(tgs, "nsec"); ret == true {
 		return ev, errors.New("STRONGEST CAUTION!! : POSTS CONTAINING PRIVATE KEYS!! YOUR POST HAS BEEN REJECTED!!")
@@ -188,6 +265,37 @@ func mkEvent(pJson interface{}, cc confClass) (nostr.Event, error) {
 
 // }}}
 
+/* contentTags {{{
+
+WHAT'S THIS?
+Returns the tags generated from content for the kind
+("emoji" from short codes and "t" from hashtags).
+*/
+func contentTags(kind int, content string, cc confClass) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	autoTags := NewContentTagsTbl()
+	// custom emojis
+	if autoTags.contains(kind, "emoji") {
+		if err := cc.setCustomEmoji(content, &tgs); err != nil {
+			return nil, err
+		}
+	}
+
+	// hashtags
+	if autoTags.contains(kind, "t") {
+		tmpstr, err := excludeHashtagsParsign(content)
+		if err != nil {
+			return nil, err
+		}
+		if err := setHashTags(tmpstr, &tgs); err != nil {
+			return nil, err
+		}
+	}
+	return tgs, nil
+}
+
+// }}}
+
 /* getKind {{{
  */
 func getKind(pJson interface{}) (int, error) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/nbd-wtf/go-nostr"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +83,19 @@ func TestReplaceBech32(t *testing.T) {
 		}
 	}
 }
+
+func TestMkRawEventTagPolicy(t *testing.T) {
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	// an "a" pin added by another client
+	raw := `{"kind": 10001, "content": "", "tags": [["e", "` + strings.Repeat("0", 64) + `"], ["a", "30023:` + strings.Repeat("1", 64) + `:post"]]}`
+	if _, err := mkRawEvent(raw, checkedTags, cc); err == nil {
+		t.Error("checkedTags accepted a tag that nostk does not write for the kind")
+	}
+	ev, err := mkRawEvent(raw, keptTags, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Tags) != 2 || ev.Tags.Find("a") == nil {
+		t.Errorf("keptTags changed the tags: %v", ev.Tags)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	//"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	minSuccessOption  = "--min-success"
+	defaultMinSuccess = 1
+	resultAccepted    = "accepted"
+	resultDuplicate   = "duplicate"
+	resultRejected    = "rejected"
+	resultFailed      = "failed"
+	resultSkipped     = "skipped"
+	okMessagePrefix   = "msg: "
+)
+
+/*
+publish result structure {{{
+
+Status is one of
+	accepted:  the relay sent OK true
+	duplicate: the relay already has the event
+	rejected:  the relay sent OK false (Reason is the NIP-01 prefix
+	           such as rate-limited, blocked, pow or invalid)
+	failed:    connection failure or no OK before the timeout
+	skipped:   not sent because the NIP-11 limitation of the relay
+	           would refuse the event
+*/
+type PublishResult struct {
+	Relay   string `json:"relay"`
+	Ok      bool   `json:"ok"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// }}}
+
+/* newPublishResult {{{
+
+WHAT'S THIS?
+Classifies the error of relay.Publish.
+go-nostr returns "msg: <OK message>" when the relay sent OK false.
+*/
+func newPublishResult(url string, err error, connecting bool) PublishResult {
+	r := PublishResult{Relay: url}
+	if err == nil {
+		r.Ok = true
+		r.Status = resultAccepted
+		return r
+	}
+	msg := err.Error()
+	if connecting || !strings.HasPrefix(msg, okMessagePrefix) {
+		r.Status = resultFailed
+		r.Message = msg
+		return r
+	}
+	r.Message = strings.TrimPrefix(msg, okMessagePrefix)
+	if prefix, _, ok := strings.Cut(r.Message, ":"); ok && !strings.ContainsAny(prefix, " \t") {
+		r.Reason = prefix
+	}
+	if r.Reason == "duplicate" {
+		r.Ok = true
+		r.Status = resultDuplicate
+		return r
+	}
+	r.Status = resultRejected
+	return r
+}
+
+// }}}
+
+/* PublishResult.retryable {{{
+
+WHAT'S THIS?
+Reports whether trying again later may succeed.
+Relays that blocked the event or found it invalid will not change.
+*/
+func (r PublishResult) retryable() bool {
+	switch r.Status {
+	case resultFailed:
+		return true
+	case resultRejected:
+		switch r.Reason {
+		case "", "rate-limited", "error", "auth-required":
+			return true
+		}
+	}
+	return false
+}
+
+// }}}
+
+/* printPublishResults {{{
+ */
+func printPublishResults(id string, results []PublishResult) {
+	fmt.Printf("event: %v\n", id)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELAY\tSTATUS\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", r.Relay, r.Status, r.Message)
+	}
+	w.Flush()
+}
+
+// }}}
+
+/* checkMinSuccess {{{
+
+WHAT'S THIS?
+Returns an error when fewer relays than minSuccess accepted the event,
+so that the exit code tells scripts the publish failed.
+minSuccess comes from "minSuccess" in config.json or --min-success.
+0 turns the check off.
+*/
+func checkMinSuccess(results []PublishResult, minSuccess int) error {
+	accepted := 0
+	for _, r := range results {
+		if r.Ok {
+			accepted++
+		}
+	}
+	if accepted < minSuccess {
+		return fmt.Errorf("Only %d of %d relays accepted the event (min-success %d)", accepted, len(results), minSuccess)
+	}
+	return nil
+}
+
+// }}}
+
+/* setMinSuccessOption {{{
+
+WHAT'S THIS?
+Takes --min-success out of the arguments of any subcommand
+and overrides the setting of config.json.
+*/
+func (cc *confClass) setMinSuccessOption(args []string) ([]string, error) {
+	args, v, ok, err := extractOption(args, minSuccessOption)
+	if err != nil || !ok {
+		return args, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return args, errors.New("--min-success must be a number of relays")
+	}
+	cc.ConfData.Settings.MinSuccess = n
+	return args, nil
+}
+
+// }}}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewPublishResult(t *testing.T) {
+	tests := []struct {
+		err        error
+		connecting bool
+		status     string
+		reason     string
+		ok         bool
+		retryable  bool
+	}{
+		{err: nil, status: resultAccepted, ok: true},
+		{err: errors.New("msg: duplicate: already have this event"), status: resultDuplicate, reason: "duplicate", ok: true},
+		{err: errors.New("msg: rate-limited: slow down"), status: resultRejected, reason: "rate-limited", retryable: true},
+		{err: errors.New("msg: blocked: you are banned"), status: resultRejected, reason: "blocked"},
+		{err: errors.New("msg: pow: difficulty 20 is less than 28"), status: resultRejected, reason: "pow"},
+		{err: errors.New("msg: something went wrong"), status: resultRejected, retryable: true},
+		{err: errors.New("context deadline exceeded"), status: resultFailed, retryable: true},
+		{err: errors.New("msg: dial error"), connecting: true, status: resultFailed, retryable: true},
+	}
+	for _, tt := range tests {
+		r := newPublishResult("wss://relay", tt.err, tt.connecting)
+		if r.Status != tt.status || r.Reason != tt.reason || r.Ok != tt.ok || r.retryable() != tt.retryable {
+			t.Errorf("newPublishResult(%v) = %+v, retryable %v", tt.err, r, r.retryable())
+		}
+	}
+}
+
+func TestCheckMinSuccess(t *testing.T) {
+	results := []PublishResult{
+		{Relay: "wss://a", Ok: true, Status: resultAccepted},
+		{Relay: "wss://b", Ok: true, Status: resultDuplicate},
+		{Relay: "wss://c", Status: resultFailed},
+	}
+	for min, fail := range map[int]bool{0: false, 2: false, 3: true} {
+		if err := checkMinSuccess(results, min); (err != nil) != fail {
+			t.Errorf("checkMinSuccess(%d) = %v", min, err)
+		}
+	}
+}
+
+func TestSetMinSuccessOption(t *testing.T) {
+	cc := confClass{}
+	cc.ConfData.Settings.MinSuccess = 1
+	args, err := cc.setMinSuccessOption([]string{"nostk", "pubMessage", "--min-success", "3", "hello"})
+	if err != nil || len(args) != 3 || cc.ConfData.Settings.MinSuccess != 3 {
+		t.Errorf("setMinSuccessOption = %v, %v, %d", args, err, cc.ConfData.Settings.MinSuccess)
+	}
+	if _, err := cc.setMinSuccessOption([]string{"nostk", "pubMessage", "--min-success=x"}); err == nil {
+		t.Error("expected error for invalid number")
+	}
+}
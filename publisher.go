@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConnectTimeout = "10s"
+	defaultOkTimeout      = "10s"
+)
+
+/*
+Publisher {{{
+
+WHAT'S THIS?
+Sends signed events to relays in parallel.
+Connections are kept for the run, so commands that publish several
+events (queue run, outbox flush) connect to each relay once.
+A relay that could not be connected is not tried again in the run.
+Relays that ask for NIP-42 AUTH are answered according to relays.json.
+Events are checked against the NIP-11 limitation of each relay first.
+*/
+type Publisher struct {
+	connectTimeout time.Duration
+	okTimeout      time.Duration
+	auth           *RelayAuth
+	info           *RelayInfoCache
+
+	mu     sync.Mutex
+	relays map[string]*nostr.Relay
+	failed map[string]error
+}
+
+func newPublisher(cc confClass) *Publisher {
+	st := cc.ConfData.Settings
+	connectTimeout := parseDurationOr(st.ConnectTimeout, defaultConnectTimeout)
+	return &Publisher{
+		connectTimeout: connectTimeout,
+		okTimeout:      parseDurationOr(st.OkTimeout, defaultOkTimeout),
+		auth:           newRelayAuth(cc),
+		info:           newRelayInfoCache(cc, connectTimeout),
+		relays:         make(map[string]*nostr.Relay),
+		failed:         make(map[string]error),
+	}
+}
+
+// parseDurationOr parses s, or def when s is empty or invalid.
+func parseDurationOr(s string, def string) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && 0 < d {
+		return d
+	}
+	d, _ := time.ParseDuration(def)
+	return d
+}
+
+// }}}
+
+/* Publisher.relay {{{
+
+WHAT'S THIS?
+Returns the connection to url. authRequired authenticates relays set
+to "on-demand" right after connecting.
+*/
+func (p *Publisher) relay(url string, authRequired bool) (*nostr.Relay, error) {
+	p.mu.Lock()
+	r, ok := p.relays[url]
+	err := p.failed[url]
+	p.mu.Unlock()
+	if ok && r.IsConnected() {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.connectTimeout)
+	defer cancel()
+	r, err = nostr.RelayConnect(ctx, url)
+	if err == nil {
+		actx, acancel := context.WithTimeout(context.Background(), authChallengeWait+p.okTimeout)
+		defer acancel()
+		if aerr := p.auth.prepare(actx, r, authRequired); aerr != nil {
+			fmt.Fprintf(os.Stderr, "AUTH to %v failed: %v\n", url, aerr)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.failed[url] = err
+		return nil, err
+	}
+	p.relays[url] = r
+	return r, nil
+}
+
+// }}}
+
+/* Publisher.publish {{{
+
+WHAT'S THIS?
+Publishes ev to every relay of rl at the same time and returns the
+results in the order of rl. Relays whose NIP-11 limitation would
+refuse ev are skipped.
+*/
+func (p *Publisher) publish(ev nostr.Event, rl []string) []PublishResult {
+	results := make([]PublishResult, len(rl))
+	var wg sync.WaitGroup
+	for i, url := range rl {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			lim := p.info.get(url, false).limitation()
+			if reason := checkRelayLimits(ev, lim); reason != "" {
+				results[i] = PublishResult{Relay: url, Status: resultSkipped, Message: reason}
+				return
+			}
+			authRequired := lim != nil && lim.AuthRequired
+			if authRequired && p.auth.mode(url) == authNever {
+				results[i] = PublishResult{Relay: url, Status: resultSkipped, Message: "auth_required, but auth is never for this relay"}
+				return
+			}
+			r, err := p.relay(url, authRequired)
+			if err != nil {
+				results[i] = newPublishResult(url, err, true)
+				return
+			}
+			results[i] = p.send(url, r, ev)
+		}(i, url)
+	}
+	wg.Wait()
+	p.info.flush()
+	return results
+}
+
+// }}}
+
+/* Publisher.send {{{
+
+WHAT'S THIS?
+Publishes ev to one relay. When the relay answers auth-required,
+authenticates and publishes once more.
+*/
+func (p *Publisher) send(url string, r *nostr.Relay, ev nostr.Event) PublishResult {
+	ctx, cancel := context.WithTimeout(context.Background(), p.okTimeout)
+	defer cancel()
+	res := newPublishResult(url, r.Publish(ctx, ev), false)
+	if res.Reason != "auth-required" || p.auth.mode(url) == authNever {
+		return res
+	}
+
+	actx, acancel := context.WithTimeout(context.Background(), p.okTimeout)
+	defer acancel()
+	if err := p.auth.authenticate(actx, r); err != nil {
+		res.Message = fmt.Sprintf("%v (AUTH failed: %v)", res.Message, err)
+		return res
+	}
+	rctx, rcancel := context.WithTimeout(context.Background(), p.okTimeout)
+	defer rcancel()
+	return newPublishResult(url, r.Publish(rctx, ev), false)
+}
+
+// }}}
+
+/* Publisher.close {{{
+ */
+func (p *Publisher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for url, r := range p.relays {
+		r.Close()
+		delete(p.relays, url)
+	}
+}
+
+// }}}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"testing"
+	"time"
+)
+
+func TestPublisherPublish(t *testing.T) {
+	accept := newTestRelay(t, &testRelay{}).URL
+	block := newTestRelay(t, &testRelay{Reply: func(ev nostr.Event) (bool, string) {
+		return false, "blocked: not allowed"
+	}}).URL
+	hang1 := newTestRelay(t, &testRelay{NoOK: true}).URL
+	hang2 := newTestRelay(t, &testRelay{NoOK: true}).URL
+
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.ConnectTimeout = "1s"
+	cc.ConfData.Settings.OkTimeout = "500ms"
+	p := newPublisher(cc)
+	defer p.close()
+
+	ev := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "test"}
+	ev.Sign(nostr.GeneratePrivateKey())
+
+	rl := []string{accept, block, hang1, hang2, "ws://127.0.0.1:1"}
+	start := time.Now()
+	results := p.publish(ev, rl)
+	// the hanging relays time out together, not one after another
+	if elapsed := time.Since(start); 900*time.Millisecond < elapsed {
+		t.Errorf("publish took %v", elapsed)
+	}
+
+	want := []string{resultAccepted, resultRejected, resultFailed, resultFailed, resultFailed}
+	for i, r := range results {
+		if r.Relay != rl[i] || r.Status != want[i] {
+			t.Errorf("result %d = %+v, want %v", i, r, want[i])
+		}
+	}
+	if results[1].Reason != "blocked" {
+		t.Errorf("reason = %q", results[1].Reason)
+	}
+
+	// the connection is reused for the next event
+	first := p.relays[accept]
+	ev2 := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "test 2"}
+	ev2.Sign(nostr.GeneratePrivateKey())
+	if r := p.publish(ev2, []string{accept}); !r[0].Ok {
+		t.Errorf("second publish = %+v", r[0])
+	}
+	if p.relays[accept] != first {
+		t.Error("relay connection was not reused")
+	}
+}
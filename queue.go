@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	queueDir      = "queue"
+	queueExt      = ".json"
+	queueLock     = ".lock"
+	queuePending  = "pending"
+	queueSent     = "sent"
+	queueCanceled = "canceled"
+	queueExpired  = "expired"
+)
+
+/*
+queued event structure {{{
+
+The event is kept unsigned and signed by "queue run",
+so created_at is the time it is actually published.
+*/
+type QueuedEvent struct {
+	Id      string          `json:"id"`
+	At      nostr.Timestamp `json:"at"`
+	Status  string          `json:"status"`
+	Raw     RawArg          `json:"raw"`
+	EventId string          `json:"event_id,omitempty"`
+	SentAt  nostr.Timestamp `json:"sent_at,omitempty"`
+	Results []PublishResult `json:"results,omitempty"`
+}
+
+// }}}
+
+/* queue file helpers {{{
+ */
+func (cc *confClass) queuePath(id string) (string, error) {
+	d, err := cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	d = filepath.Join(d, queueDir)
+	if err := os.MkdirAll(d, 0700); err != nil {
+		return "", err
+	}
+	if id == "" {
+		return d, nil
+	}
+	if strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("Invalid queue id %q", id)
+	}
+	return filepath.Join(d, id+queueExt), nil
+}
+func (cc *confClass) loadQueuedEvent(id string) (QueuedEvent, error) {
+	var q QueuedEvent
+	path, err := cc.queuePath(id)
+	if err != nil {
+		return q, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return q, fmt.Errorf("Not found queued event %q", id)
+	}
+	if err := json.Unmarshal(b, &q); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+func (cc *confClass) saveQueuedEvent(q QueuedEvent) error {
+	path, err := cc.queuePath(q.Id)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(q, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+func (cc *confClass) loadQueue() ([]QueuedEvent, error) {
+	d, err := cc.queuePath("")
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(d, "*"+queueExt))
+	if err != nil {
+		return nil, err
+	}
+	qs := []QueuedEvent{}
+	for _, f := range files {
+		q, err := cc.loadQueuedEvent(strings.TrimSuffix(filepath.Base(f), queueExt))
+		if err != nil {
+			return nil, err
+		}
+		qs = append(qs, q)
+	}
+	sort.Slice(qs, func(i, j int) bool {
+		return qs[i].At < qs[j].At
+	})
+	return qs, nil
+}
+
+// }}}
+
+/*
+	schedule {{{
+		[infomation for develop]
+		usage:
+			nostk schedule <time> <text|draft name>
+		time: duration from now ("2h"), unix time or "2006/01/02 15:04"
+		A draft is moved to the queue.
+*/
+func schedule(args []string, cc confClass) error {
+	if !cc.publishes() {
+		return errPublishMode
+	}
+	if len(args) != 4 {
+		return errors.New("Wrong number of parameters")
+	}
+	at, err := parseFutureTime(args[2])
+	if err != nil {
+		return err
+	}
+
+	q := QueuedEvent{
+		Id:     at.Time().Format(draftNameTmpl),
+		At:     at,
+		Status: queuePending,
+		Raw:    RawArg{Kind: 1, Content: args[3], Tags: nostr.Tags{}},
+	}
+	draftName := ""
+	if _, err := draftFile(args[3]); err == nil {
+		if d, err := cc.loadDraft(args[3]); err == nil {
+			if q.Raw, err = d.rawArg(cc); err != nil {
+				return err
+			}
+			draftName = d.Name
+		}
+	}
+	if err := cc.addExpiration(q.Raw.Kind, &q.Raw.Tags); err != nil {
+		return err
+	}
+	if exp := getExpiration(q.Raw.Tags); exp != 0 && exp <= at {
+		return errors.New("The event expires before the time to publish it")
+	}
+
+	// check the event now rather than when the time comes
+	tmp, err := json.Marshal(q.Raw)
+	if err != nil {
+		return err
+	}
+	if _, err := mkSignedEvent(string(tmp), 0, checkedTags, cc); err != nil {
+		return err
+	}
+
+	for i := 1; ; i++ {
+		path, err := cc.queuePath(q.Id)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			break
+		}
+		q.Id = fmt.Sprintf("%v-%d", at.Time().Format(draftNameTmpl), i)
+	}
+	if err := cc.saveQueuedEvent(q); err != nil {
+		return err
+	}
+	if draftName != "" {
+		if path, err := cc.draftPath(draftName); err == nil {
+			os.Remove(path)
+		}
+	}
+	fmt.Printf("scheduled: %v at %v\n", q.Id, at.Time().Format(layout))
+	return nil
+}
+
+// }}}
+
+/*
+	queue {{{
+		[infomation for develop]
+		usage:
+			nostk queue list
+			nostk queue cancel <id>
+			nostk queue run
+*/
+func queue(args []string, cc confClass) error {
+	if len(args) < 3 {
+		return errors.New("Not enough arguments")
+	}
+	switch args[2] {
+	case "list":
+		return listQueue(cc)
+	case "cancel":
+		if len(args) != 4 {
+			return errors.New("Wrong number of parameters")
+		}
+		q, err := cc.loadQueuedEvent(args[3])
+		if err != nil {
+			return err
+		}
+		if q.Status != queuePending {
+			return fmt.Errorf("%v is already %v", q.Id, q.Status)
+		}
+		q.Status = queueCanceled
+		return cc.saveQueuedEvent(q)
+	case "run":
+		return runQueue(cc)
+	}
+	return fmt.Errorf("Unknown queue command %v", args[2])
+}
+
+// }}}
+
+/* listQueue {{{
+ */
+type QueueInfo struct {
+	Id       string   `json:"id"`
+	At       string   `json:"at"`
+	Status   string   `json:"status"`
+	Kind     int      `json:"kind"`
+	Head     string   `json:"head"`
+	SentAt   string   `json:"sent_at,omitempty"`
+	Accepted []string `json:"accepted,omitempty"`
+}
+
+func listQueue(cc confClass) error {
+	qs, err := cc.loadQueue()
+	if err != nil {
+		return err
+	}
+	infos := []QueueInfo{}
+	for _, q := range qs {
+		head := strings.SplitN(q.Raw.Content, "\n", 2)[0]
+		if r := []rune(head); 40 < len(r) {
+			head = string(r[:40]) + "..."
+		}
+		info := QueueInfo{
+			Id:     q.Id,
+			At:     q.At.Time().Format(layout),
+			Status: q.Status,
+			Kind:   q.Raw.Kind,
+			Head:   head,
+		}
+		if 0 < q.SentAt {
+			info.SentAt = q.SentAt.Time().Format(layout)
+		}
+		for _, r := range q.Results {
+			if r.Ok {
+				info.Accepted = append(info.Accepted, r.Relay)
+			}
+		}
+		infos = append(infos, info)
+	}
+	return printJson(infos)
+}
+
+// }}}
+
+/* runQueue {{{
+
+WHAT'S THIS?
+Signs and publishes the queued events whose time has come.
+Meant to be run from cron. A lock file keeps two runs from
+publishing the same event. Relays that failed are retried
+through the outbox with the same signed event.
+*/
+func runQueue(cc confClass) error {
+	if !cc.publishes() {
+		return errPublishMode
+	}
+	d, err := cc.queuePath("")
+	if err != nil {
+		return err
+	}
+	lock := filepath.Join(d, queueLock)
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("queue run is already running. Remove %v if it is not.", lock)
+	}
+	f.Close()
+	defer os.Remove(lock)
+
+	qs, err := cc.loadQueue()
+	if err != nil {
+		return err
+	}
+	var rl []string
+	if err := cc.getRelayList(&rl, writeFlag); err != nil {
+		return err
+	}
+
+	p := newPublisher(cc)
+	defer p.close()
+	var errs []error
+	now := nostr.Now()
+	for _, q := range qs {
+		if q.Status != queuePending || now < q.At {
+			continue
+		}
+		if isExpired(q.Raw.Tags, now) {
+			fmt.Printf("expired: %v\n", q.Id)
+			q.Status = queueExpired
+			if err := cc.saveQueuedEvent(q); err != nil {
+				return err
+			}
+			continue
+		}
+		tmp, err := json.Marshal(q.Raw)
+		if err != nil {
+			return err
+		}
+		ev, err := mkSignedEvent(string(tmp), cc.powTarget(rl), checkedTags, cc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", q.Id, err))
+			continue
+		}
+		fmt.Printf("queued: %v\n", q.Id)
+		if q.Results, err = deliverEvent(p, ev, rl, cc); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", q.Id, err))
+		}
+		q.Status = queueSent
+		q.EventId = ev.ID
+		q.SentAt = ev.CreatedAt
+		if err := cc.saveQueuedEvent(q); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
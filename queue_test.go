@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"testing"
+)
+
+func TestQueueStore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cc := confClass{}
+
+	for _, q := range []QueuedEvent{
+		{Id: "later", At: 2000, Status: queuePending, Raw: RawArg{Kind: 1, Content: "later\nsecond line"}},
+		{Id: "sooner", At: 1000, Status: queuePending, Raw: RawArg{Kind: 1, Content: "sooner"}},
+	} {
+		if err := cc.saveQueuedEvent(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	qs, err := cc.loadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qs) != 2 || qs[0].Id != "sooner" || qs[1].Raw.Content != "later\nsecond line" {
+		t.Fatalf("loadQueue = %+v", qs)
+	}
+
+	if err := queue([]string{"nostk", "queue", "cancel", "sooner"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := cc.loadQueuedEvent("sooner"); err != nil || q.Status != queueCanceled {
+		t.Errorf("cancel: %+v, %v", q, err)
+	}
+	if err := queue([]string{"nostk", "queue", "cancel", "sooner"}, cc); err == nil {
+		t.Error("canceling twice should fail")
+	}
+	if _, err := cc.queuePath("../x"); err == nil {
+		t.Error("queuePath should reject path separators")
+	}
+
+	sent := QueuedEvent{Id: "sent", At: 1, Status: queueSent, Results: []PublishResult{{Relay: "wss://a", Ok: true}}, SentAt: nostr.Timestamp(2)}
+	if err := cc.saveQueuedEvent(sent); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := cc.loadQueuedEvent("sent"); err != nil || len(q.Results) != 1 || !q.Results[0].Ok {
+		t.Errorf("results were not kept: %+v, %v", q, err)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	//"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	authorOption    = "--author"
+	toOption        = "--to"
+	rebroadcastPage = 500
+)
+
+/*
+	rebroadcast {{{
+		[infomation for develop]
+		usage:
+			nostk rebroadcast <id|note|nevent|naddr...> [--to url...]
+			nostk rebroadcast --author <npub> [number] [--to url...]
+		Events are read from every known relay (relays.json, relay hints
+		and the NIP-65 relays of the author), verified by subManyEose and
+		published unchanged to the write relays or to --to.
+*/
+func rebroadcast(args []string, cc confClass) error {
+	args, to, err := extractOptions(args, toOption)
+	if err != nil {
+		return err
+	}
+	args, author, hasAuthor, err := extractOption(args, authorOption)
+	if err != nil {
+		return err
+	}
+
+	var rl []string
+	if 0 < len(to) {
+		rl = uniqueRelays(to)
+	} else if err := cc.getRelayList(&rl, writeFlag); err != nil {
+		return err
+	}
+	if len(rl) < 1 {
+		return errors.New("No relays to publish to")
+	}
+
+	var evs []*nostr.Event
+	var errs []error
+	if hasAuthor {
+		if 3 < len(args) {
+			return errors.New("Too meny argument")
+		}
+		max := 0
+		if len(args) == 3 {
+			if max, err = strconv.Atoi(args[2]); err != nil || max < 1 {
+				return errors.New("number must be a positive number")
+			}
+		}
+		pk, err := toHexId(author, "npub", "nprofile")
+		if err != nil {
+			return err
+		}
+		if evs, err = fetchAuthorHistory(cc, pk, max); err != nil {
+			return err
+		}
+	} else {
+		if len(args) < 3 {
+			return errors.New("Not set event ids")
+		}
+		var missing int
+		if evs, missing, err = fetchTargets(cc, args[2:]); err != nil {
+			return err
+		}
+		if 0 < missing && 0 < len(evs) {
+			errs = append(errs, fmt.Errorf("%d of %d events not found", missing, len(args[2:])))
+		}
+	}
+	if len(evs) < 1 {
+		return errEventNotFound
+	}
+
+	sort.Slice(evs, func(i, j int) bool {
+		return evs[i].CreatedAt < evs[j].CreatedAt
+	})
+	fmt.Printf("rebroadcasting %d events to %d relays\n", len(evs), len(rl))
+	p := newPublisher(cc)
+	defer p.close()
+	for _, ev := range evs {
+		if _, err := deliverEvent(p, *ev, rl, cc); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", ev.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// }}}
+
+/* knownRelays {{{
+
+WHAT'S THIS?
+Returns every relay of relays.json and hints, without duplicates.
+*/
+func (cc confClass) knownRelays(hints ...string) ([]string, error) {
+	var rs []string
+	if err := cc.getRelayList(&rs, readWriteFlag); err != nil {
+		return nil, err
+	}
+	return uniqueRelays(append(rs, hints...)), nil
+}
+
+// uniqueRelays normalizes urls and removes duplicates, keeping the order.
+func uniqueRelays(urls []string) []string {
+	seen := make(map[string]struct{})
+	ret := []string{}
+	for _, url := range urls {
+		for _, u := range strings.Split(url, ",") {
+			u = nostr.NormalizeURL(strings.TrimSpace(u))
+			if u == "" {
+				continue
+			}
+			if _, ok := seen[u]; ok {
+				continue
+			}
+			seen[u] = struct{}{}
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
+
+// }}}
+
+/* fetchTargets {{{
+
+WHAT'S THIS?
+Reads the events of ids (hex, note or nevent) and the newest event of
+each naddr from every known relay and the relay hints.
+Targets that no relay has are reported to stderr, left out and counted
+in the second result.
+*/
+func fetchTargets(cc confClass, targets []string) ([]*nostr.Event, int, error) {
+	ids := []string{}
+	eps := []nostr.EntityPointer{}
+	hints := []string{}
+	for _, target := range targets {
+		if strings.HasPrefix(target, "naddr") {
+			ep, err := toEntityPointer(target)
+			if err != nil {
+				return nil, 0, err
+			}
+			eps = append(eps, ep)
+			hints = append(hints, ep.Relays...)
+			continue
+		}
+		if strings.HasPrefix(target, "nevent") {
+			if _, data, err := toHex(target); err == nil {
+				if ep, ok := data.(nostr.EventPointer); ok {
+					ids = append(ids, ep.ID)
+					hints = append(hints, ep.Relays...)
+					continue
+				}
+			}
+			return nil, 0, fmt.Errorf("Invalid id %v", target)
+		}
+		id, err := toHexId(target, "note")
+		if err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rs, err := cc.knownRelays(hints...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filters := nostr.Filters{}
+	if 0 < len(ids) {
+		filters = append(filters, nostr.Filter{IDs: ids, Limit: len(ids)})
+	}
+	for _, ep := range eps {
+		filters = append(filters, ep.AsFilter())
+	}
+	byId := make(map[string]*nostr.Event)
+	byAddr := make(map[string]*nostr.Event)
+	for _, rev := range fetchRelayEventsFrom(cc, rs, filters, len(targets)) {
+		ev := rev.Event
+		byId[ev.ID] = ev
+		if nostr.IsAddressableKind(ev.Kind) || nostr.IsReplaceableKind(ev.Kind) {
+			addr := fmt.Sprintf("%d:%v:%v", ev.Kind, ev.PubKey, ev.Tags.GetD())
+			if v, ok := byAddr[addr]; !ok || v.CreatedAt < ev.CreatedAt {
+				byAddr[addr] = ev
+			}
+		}
+	}
+
+	evs := []*nostr.Event{}
+	missing := 0
+	for _, id := range ids {
+		if ev, ok := byId[id]; ok {
+			evs = append(evs, ev)
+		} else {
+			fmt.Fprintf(os.Stderr, "Not found: %v\n", id)
+			missing++
+		}
+	}
+	for _, ep := range eps {
+		addr := fmt.Sprintf("%d:%v:%v", ep.Kind, ep.PublicKey, ep.Identifier)
+		if ev, ok := byAddr[addr]; ok {
+			evs = append(evs, ev)
+		} else {
+			fmt.Fprintf(os.Stderr, "Not found: %v\n", addr)
+			missing++
+		}
+	}
+	return evs, missing, nil
+}
+
+// }}}
+
+/* fetchAuthorHistory {{{
+
+WHAT'S THIS?
+Reads the events of pk, newest first, from every known relay and the
+write relays of the NIP-65 relay list (kind 10002) of pk.
+Stops after max events when max is not 0.
+*/
+func fetchAuthorHistory(cc confClass, pk string, max int) ([]*nostr.Event, error) {
+	rs, err := cc.knownRelays()
+	if err != nil {
+		return nil, err
+	}
+	rs = uniqueRelays(append(rs, authorWriteRelays(cc, rs, pk)...))
+
+	evs := []*nostr.Event{}
+	fetchAllEvents(cc, rs, nostr.Filter{Authors: []string{pk}}, rebroadcastPage, max,
+		func(page []nostr.RelayEvent) bool {
+			for _, rev := range page {
+				evs = append(evs, rev.Event)
+			}
+			return true
+		})
+	return evs, nil
+}
+
+// authorWriteRelays returns the write relays in the kind 10002 of pk.
+func authorWriteRelays(cc confClass, rs []string, pk string) []string {
+	filter := nostr.Filter{Kinds: []int{nostr.KindRelayListMetadata}, Authors: []string{pk}, Limit: singleReadNo}
+	var latest *nostr.Event
+	for _, rev := range fetchRelayEventsFrom(cc, rs, nostr.Filters{filter}, singleReadNo) {
+		if latest == nil || latest.CreatedAt < rev.CreatedAt {
+			latest = rev.Event
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	ret := []string{}
+	for _, tg := range latest.Tags {
+		// no marker means both read and write
+		if 1 < len(tg) && tg[0] == "r" && (len(tg) < 3 || tg[2] == "write") {
+			ret = append(ret, tg[1])
+		}
+	}
+	return ret
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestUniqueRelays(t *testing.T) {
+	got := uniqueRelays([]string{"wss://a.example.com/", "wss://b.example.com,wss://a.example.com", " ", "b.example.com"})
+	want := []string{"wss://a.example.com", "wss://b.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueRelays = %v", got)
+	}
+}
+
+func TestRebroadcast(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	older := nostr.Event{Kind: 1, CreatedAt: 1000, Content: "older", Tags: nostr.Tags{}}
+	older.Sign(sk)
+	newer := nostr.Event{Kind: 1, CreatedAt: 2000, Content: "newer", Tags: nostr.Tags{}}
+	newer.Sign(sk)
+	forged := nostr.Event{Kind: 1, CreatedAt: 1500, Content: "original", Tags: nostr.Tags{}}
+	forged.Sign(sk)
+	forged.Content = "forged"
+
+	source := newTestRelay(t, &testRelay{Stored: []nostr.Event{newer, forged, older}}).URL
+	write := newTestRelay(t, &testRelay{})
+	other := newTestRelay(t, &testRelay{})
+	cc := setupTestEnv(t, map[string]RwFlag{source: {Read: true}, write.URL: {Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "1s"
+	cc.ConfData.Settings.OkTimeout = "1s"
+
+	note, _ := nip19.EncodeNote(older.ID)
+	if err := rebroadcast([]string{"nostk", "rebroadcast", note, newer.ID}, cc); err != nil {
+		t.Fatal(err)
+	}
+	// published oldest first
+	if got := write.acceptedIDs(); !reflect.DeepEqual(got, []string{older.ID, newer.ID}) {
+		t.Errorf("write relay received %v", got)
+	}
+
+	npub, _ := nip19.EncodePublicKey(pk)
+	if err := rebroadcast([]string{"nostk", "rebroadcast", "--author", npub, "--to", other.URL}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.acceptedIDs(); !reflect.DeepEqual(got, []string{older.ID, newer.ID}) {
+		t.Errorf("--to relay received %v", got)
+	}
+	if got := write.acceptedIDs(); len(got) != 2 {
+		t.Errorf("write relay received %v with --to", got)
+	}
+
+	if err := rebroadcast([]string{"nostk", "rebroadcast", forged.ID}, cc); err != errEventNotFound {
+		t.Errorf("forged event: %v", err)
+	}
+	// the events found are copied, and the missing ones fail the run
+	if err := rebroadcast([]string{"nostk", "rebroadcast", newer.ID, forged.ID, "--to", other.URL}, cc); err == nil {
+		t.Error("missing event was not reported")
+	}
+	if got := other.acceptedIDs(); len(got) != 3 || got[2] != newer.ID {
+		t.Errorf("--to relay received %v", got)
+	}
+}
+
+func TestFetchAllEventsPerRelay(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	mk := func(at nostr.Timestamp) nostr.Event {
+		ev := nostr.Event{Kind: 1, CreatedAt: at, Content: "note", Tags: nostr.Tags{}}
+		ev.Sign(sk)
+		return ev
+	}
+	// the relays hold different time ranges, so one shared "until" would
+	// skip the older half of the newer relay
+	newer := newTestRelay(t, &testRelay{Stored: []nostr.Event{mk(40), mk(30), mk(20), mk(10)}})
+	older := newTestRelay(t, &testRelay{Stored: []nostr.Event{mk(3), mk(2), mk(1)}})
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+
+	got := []nostr.Timestamp{}
+	fetchAllEvents(cc, []string{newer.URL, older.URL}, nostr.Filter{Authors: []string{pk}}, 2, 0,
+		func(page []nostr.RelayEvent) bool {
+			for _, rev := range page {
+				got = append(got, rev.CreatedAt)
+			}
+			return true
+		})
+	slices.Sort(got)
+	if want := []nostr.Timestamp{1, 2, 3, 10, 20, 30, 40}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v", got)
+	}
+
+	got = got[:0]
+	fetchAllEvents(cc, []string{newer.URL, older.URL}, nostr.Filter{Authors: []string{pk}}, 2, 5,
+		func(page []nostr.RelayEvent) bool {
+			for _, rev := range page {
+				got = append(got, rev.CreatedAt)
+			}
+			return true
+		})
+	if len(got) != 5 {
+		t.Errorf("fetched %v with max 5", got)
+	}
+}
+
+func TestFetchAllEventsCappedLimit(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	mk := func(at nostr.Timestamp, tags nostr.Tags) nostr.Event {
+		ev := nostr.Event{Kind: 1, CreatedAt: at, Content: "note", Tags: tags}
+		ev.Sign(sk)
+		return ev
+	}
+	expired := mk(45, nostr.Tags{{"expiration", "100"}})
+	// max_limit 2 returns short pages, and the newest page has an expired
+	// event, but the relay is read to the end
+	r := newTestRelay(t, &testRelay{
+		Stored: []nostr.Event{mk(50, nostr.Tags{}), expired, mk(40, nostr.Tags{}), mk(30, nostr.Tags{}), mk(20, nostr.Tags{})},
+		Limits: &nip11.RelayLimitationDocument{MaxLimit: 2},
+	})
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+
+	got := []nostr.Timestamp{}
+	fetchAllEvents(cc, []string{r.URL}, nostr.Filter{Authors: []string{pk}}, 4, 0,
+		func(page []nostr.RelayEvent) bool {
+			for _, rev := range page {
+				got = append(got, rev.CreatedAt)
+			}
+			return true
+		})
+	slices.Sort(got)
+	if want := []nostr.Timestamp{20, 30, 40, 50}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %v", got)
+	}
+}
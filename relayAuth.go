@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"io/fs"
+	//"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	authAlways   = "always"
+	authOnDemand = "on-demand"
+	authNever    = "never"
+	// go-nostr does not tell when the challenge arrives,
+	// so "always" waits this long after connecting.
+	authChallengeWait = time.Second
+)
+
+var errAuthDisabled = errors.New("AUTH is disabled for this relay")
+
+/* getRelayAuthModes {{{
+
+WHAT'S THIS?
+Reads "auth" of each relay in relays.json.
+
+	"wss://relay.example.com" : {
+		"read" : true,
+		"write" : true,
+		"auth" : "always"    // always, on-demand (default) or never
+	}
+*/
+func (cc *confClass) getRelayAuthModes() (map[string]string, error) {
+	m, err := cc.loadRelays()
+	if err != nil {
+		return nil, err
+	}
+	modes := make(map[string]string)
+	for url, v := range m {
+		switch v.Auth {
+		case "":
+			modes[nostr.NormalizeURL(url)] = authOnDemand
+		case authAlways, authOnDemand, authNever:
+			modes[nostr.NormalizeURL(url)] = v.Auth
+		default:
+			return nil, fmt.Errorf("Invalid auth %q for %v in %v", v.Auth, url, cc.ConfData.Filename.Relays)
+		}
+	}
+	return modes, nil
+}
+
+// }}}
+
+/*
+RelayAuth {{{
+
+WHAT'S THIS?
+Answers NIP-42 AUTH challenges with a kind 22242 event signed by your key.
+*/
+type RelayAuth struct {
+	cc    confClass
+	modes map[string]string
+
+	once sync.Once
+	sk   string
+	err  error
+}
+
+func newRelayAuth(cc confClass) *RelayAuth {
+	modes, err := cc.getRelayAuthModes()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		modes = map[string]string{}
+	}
+	return &RelayAuth{cc: cc, modes: modes}
+}
+
+func (r *RelayAuth) mode(url string) string {
+	if m, ok := r.modes[nostr.NormalizeURL(url)]; ok {
+		return m
+	}
+	return authOnDemand
+}
+
+func (r *RelayAuth) sign(ev *nostr.Event) error {
+	r.once.Do(func() {
+		r.sk, r.err = r.cc.load(r.cc.ConfData.Filename.Hsec)
+	})
+	if r.err != nil {
+		return r.err
+	}
+	return ev.Sign(r.sk)
+}
+
+// }}}
+
+/* RelayAuth.authenticate {{{
+ */
+func (r *RelayAuth) authenticate(ctx context.Context, relay *nostr.Relay) error {
+	if r.mode(relay.URL) == authNever {
+		return errAuthDisabled
+	}
+	return relay.Auth(ctx, r.sign)
+}
+
+// }}}
+
+/* RelayAuth.prepare {{{
+
+WHAT'S THIS?
+Authenticates right after connecting to relays set to "always", and to
+relays set to "on-demand" whose NIP-11 document says auth_required.
+Other relays authenticate when they answer auth-required.
+*/
+func (r *RelayAuth) prepare(ctx context.Context, relay *nostr.Relay, required bool) error {
+	if m := r.mode(relay.URL); m != authAlways && !(required && m == authOnDemand) {
+		return nil
+	}
+	select {
+	case <-time.After(authChallengeWait):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.authenticate(ctx, relay)
+}
+
+// }}}
+
+/* newPool {{{
+
+WHAT'S THIS?
+Makes the pool for reading from urls.
+Subscriptions closed with auth-required are authenticated and sent
+again by go-nostr. Relays set to "always" are authenticated first.
+Events are verified by subManyEose, not by go-nostr.
+*/
+func (cc *confClass) newPool(ctx context.Context, urls []string) *nostr.SimplePool {
+	ra := newRelayAuth(*cc)
+	pool := nostr.NewSimplePool(ctx,
+		nostr.WithRelayOptions(verifyLocally{}),
+		nostr.WithAuthHandler(func(ctx context.Context, ae nostr.RelayEvent) error {
+			if ra.mode(ae.Relay.URL) == authNever {
+				return errAuthDisabled
+			}
+			return ra.sign(ae.Event)
+		}))
+
+	okTimeout := parseDurationOr(cc.ConfData.Settings.OkTimeout, defaultOkTimeout)
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		if ra.mode(url) != authAlways {
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relay, err := pool.EnsureRelay(url)
+			if err != nil {
+				return
+			}
+			actx, cancel := context.WithTimeout(ctx, authChallengeWait+okTimeout)
+			defer cancel()
+			if err := ra.prepare(actx, relay, false); err != nil {
+				fmt.Fprintf(os.Stderr, "AUTH to %v failed: %v\n", url, err)
+			}
+		}(url)
+	}
+	wg.Wait()
+	return pool
+}
+
+// }}}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"testing"
+)
+
+func TestPublisherAuth(t *testing.T) {
+	for _, mode := range []string{"", authOnDemand, authAlways, authNever} {
+		s := newTestRelay(t, &testRelay{Auth: true})
+		cc := setupTestEnv(t, map[string]RwFlag{s.URL: {Write: true, Auth: mode}})
+		p := newPublisher(cc)
+
+		ev := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "members"}
+		ev.Sign(nostr.GeneratePrivateKey())
+		r := p.publish(ev, []string{s.URL})[0]
+		p.close()
+
+		switch mode {
+		case authNever:
+			if r.Ok || r.Reason != "auth-required" {
+				t.Errorf("never: %+v", r)
+			}
+		case authAlways:
+			if !r.Ok || s.Rejected.Load() != 0 {
+				t.Errorf("always: %+v, rejected %d", r, s.Rejected.Load())
+			}
+		default:
+			if !r.Ok || s.Rejected.Load() != 1 {
+				t.Errorf("on-demand %q: %+v, rejected %d", mode, r, s.Rejected.Load())
+			}
+		}
+	}
+}
+
+func TestFetchWithAuth(t *testing.T) {
+	stored := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "stored"}
+	stored.Sign(nostr.GeneratePrivateKey())
+	s := newTestRelay(t, &testRelay{Auth: true, Stored: []nostr.Event{stored}})
+	cc := setupTestEnv(t, map[string]RwFlag{s.URL: {Read: true}})
+
+	evs, err := fetchEvents(cc, nostr.Filters{{Kinds: []int{1}, Limit: 1}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != stored.ID {
+		t.Errorf("fetchEvents = %v", evs)
+	}
+}
+
+func TestGetRelayAuthModes(t *testing.T) {
+	cc := setupTestEnv(t, map[string]RwFlag{
+		"wss://a.example.com":  {Read: true},
+		"wss://b.example.com/": {Read: true, Auth: authNever},
+	})
+	modes, err := cc.getRelayAuthModes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modes["wss://a.example.com"] != authOnDemand || modes["wss://b.example.com"] != authNever {
+		t.Errorf("modes = %v", modes)
+	}
+
+	cc = setupTestEnv(t, map[string]RwFlag{"wss://a.example.com": {Auth: "sometimes"}})
+	if _, err := cc.getRelayAuthModes(); err == nil {
+		t.Error(fmt.Sprintf("expected error for invalid mode, got %v", modes))
+	}
+}
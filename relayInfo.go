@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip13"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	relayInfoFile     = "relayinfo.json"
+	relayInfoTTL      = 24 * time.Hour
+	relayInfoErrorTTL = time.Hour
+)
+
+/*
+relay information structure {{{
+
+NIP-11 documents are cached in ~/.nostk/relayinfo.json.
+A relay without a document is cached with Error for a shorter time,
+so that every publish does not wait for it again.
+*/
+type RelayInfoEntry struct {
+	Relay     string                          `json:"relay"`
+	FetchedAt nostr.Timestamp                 `json:"fetched_at"`
+	Error     string                          `json:"error,omitempty"`
+	Info      *nip11.RelayInformationDocument `json:"info,omitempty"`
+}
+
+// fresh reports whether the entry can be used at now.
+func (r RelayInfoEntry) fresh(now time.Time) bool {
+	ttl := relayInfoTTL
+	if r.Error != "" {
+		ttl = relayInfoErrorTTL
+	}
+	return now.Before(r.FetchedAt.Time().Add(ttl))
+}
+
+// limitation returns the limitation of the document, or nil.
+func (r RelayInfoEntry) limitation() *nip11.RelayLimitationDocument {
+	if r.Info == nil {
+		return nil
+	}
+	return r.Info.Limitation
+}
+
+// }}}
+
+/*
+RelayInfoCache {{{
+
+WHAT'S THIS?
+Loads and saves relayinfo.json. Safe for concurrent use by Publisher.
+Fetched documents are kept in memory until flush, so that parallel
+fetches write the file once.
+*/
+type RelayInfoCache struct {
+	cc      confClass
+	timeout time.Duration
+
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]RelayInfoEntry
+}
+
+func newRelayInfoCache(cc confClass, timeout time.Duration) *RelayInfoCache {
+	return &RelayInfoCache{cc: cc, timeout: timeout, entries: map[string]RelayInfoEntry{}}
+}
+
+func (c *RelayInfoCache) path() (string, error) {
+	d, err := c.cc.getDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, relayInfoFile), nil
+}
+
+// load reads the cache file once. A missing file is an empty cache.
+func (c *RelayInfoCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	path, err := c.path()
+	if err != nil {
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring broken %v: %v\n", relayInfoFile, err)
+		c.entries = map[string]RelayInfoEntry{}
+	}
+}
+
+func (c *RelayInfoCache) save() error {
+	path, err := c.path()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// flush saves the documents fetched since the last flush.
+func (c *RelayInfoCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+	if err := c.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save %v: %v\n", relayInfoFile, err)
+		return
+	}
+	c.dirty = false
+}
+
+// }}}
+
+/* RelayInfoCache.get {{{
+
+WHAT'S THIS?
+Returns the document of url from the cache, fetching it when the
+cached one is stale or refresh is true. Call flush after the fetches
+to save them.
+*/
+func (c *RelayInfoCache) get(url string, refresh bool) RelayInfoEntry {
+	url = nostr.NormalizeURL(url)
+	c.mu.Lock()
+	c.load()
+	e, ok := c.entries[url]
+	c.mu.Unlock()
+	if ok && !refresh && e.fresh(time.Now()) {
+		return e
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	info, err := nip11.Fetch(ctx, url)
+	e = RelayInfoEntry{Relay: url, FetchedAt: nostr.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Info = &info
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = e
+	c.dirty = true
+	return e
+}
+
+// }}}
+
+/* checkRelayLimits {{{
+
+WHAT'S THIS?
+Checks ev against the NIP-11 limitation of a relay before publishing.
+Returns why the relay would refuse ev, or "" when ev fits.
+auth_required is not checked here; Publisher authenticates first.
+*/
+func checkRelayLimits(ev nostr.Event, lim *nip11.RelayLimitationDocument) string {
+	if lim == nil {
+		return ""
+	}
+	if n := utf8.RuneCountInString(ev.Content); 0 < lim.MaxContentLength && lim.MaxContentLength < n {
+		return fmt.Sprintf("content has %d characters, max_content_length is %d", n, lim.MaxContentLength)
+	}
+	if 0 < lim.MaxEventTags && lim.MaxEventTags < len(ev.Tags) {
+		return fmt.Sprintf("event has %d tags, max_event_tags is %d", len(ev.Tags), lim.MaxEventTags)
+	}
+	if d := nip13.Difficulty(ev.ID); d < lim.MinPowDifficulty {
+		return fmt.Sprintf("PoW difficulty is %d, min_pow_difficulty is %d", d, lim.MinPowDifficulty)
+	}
+	return ""
+}
+
+// }}}
+
+/*
+	relayInfo {{{
+		[infomation for develop]
+		usage:
+			nostk relayInfo [url]
+*/
+func relayInfo(args []string, cc confClass) error {
+	var rl []string
+	switch len(args) {
+	case 2:
+		if err := cc.getRelayList(&rl, readWriteFlag); err != nil {
+			return err
+		}
+		sort.Strings(rl)
+	case 3:
+		rl = []string{args[2]}
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+	if len(rl) < 1 {
+		return errors.New("No relays")
+	}
+
+	c := newRelayInfoCache(cc, parseDurationOr(cc.ConfData.Settings.ConnectTimeout, defaultConnectTimeout))
+	es := make([]RelayInfoEntry, len(rl))
+	var wg sync.WaitGroup
+	for i, url := range rl {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			es[i] = c.get(url, true)
+		}(i, url)
+	}
+	wg.Wait()
+	c.flush()
+	return printJson(es)
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRelayLimits(t *testing.T) {
+	ev := nostr.Event{
+		Kind:    1,
+		Content: "こんにちは",
+		Tags:    nostr.Tags{{"t", "a"}, {"t", "b"}},
+	}
+	ev.ID = "00000fff" + strings.Repeat("f", 56) // difficulty 20
+
+	tests := []struct {
+		lim  *nip11.RelayLimitationDocument
+		skip bool
+	}{
+		{nil, false},
+		{&nip11.RelayLimitationDocument{}, false},
+		{&nip11.RelayLimitationDocument{MaxContentLength: 5}, false},
+		{&nip11.RelayLimitationDocument{MaxContentLength: 4}, true},
+		{&nip11.RelayLimitationDocument{MaxEventTags: 2}, false},
+		{&nip11.RelayLimitationDocument{MaxEventTags: 1}, true},
+		{&nip11.RelayLimitationDocument{MinPowDifficulty: 20}, false},
+		{&nip11.RelayLimitationDocument{MinPowDifficulty: 21}, true},
+	}
+	for i, tt := range tests {
+		if reason := checkRelayLimits(ev, tt.lim); (reason != "") != tt.skip {
+			t.Errorf("%d: checkRelayLimits = %q, want skip %v", i, reason, tt.skip)
+		}
+	}
+}
+
+func TestPublisherRelayLimits(t *testing.T) {
+	r := newTestRelay(t, &testRelay{Limits: &nip11.RelayLimitationDocument{MaxContentLength: 10}})
+	url := r.URL
+
+	cc := setupTestEnv(t, map[string]RwFlag{})
+	cc.ConfData.Settings.ConnectTimeout = "1s"
+	cc.ConfData.Settings.OkTimeout = "1s"
+	sk := nostr.GeneratePrivateKey()
+
+	p := newPublisher(cc)
+	long := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "longer than ten characters"}
+	long.Sign(sk)
+	if r := p.publish(long, []string{url})[0]; r.Status != resultSkipped || r.retryable() {
+		t.Errorf("long event = %+v", r)
+	}
+	if r.Received.Load() != 0 {
+		t.Error("skipped event was sent")
+	}
+	p.close()
+
+	// a new run reads the document from the cache
+	p = newPublisher(cc)
+	defer p.close()
+	short := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "short"}
+	short.Sign(sk)
+	if r := p.publish(short, []string{url})[0]; !r.Ok {
+		t.Errorf("short event = %+v", r)
+	}
+	if n := r.InfoHits.Load(); n != 1 {
+		t.Errorf("NIP-11 document fetched %d times", n)
+	}
+}
+
+func TestRelayInfoCacheFlush(t *testing.T) {
+	a := newTestRelay(t, &testRelay{NIPs: []int{1}})
+	b := newTestRelay(t, &testRelay{NIPs: []int{1, 50}})
+	cc := setupTestEnv(t, map[string]RwFlag{})
+
+	c := newRelayInfoCache(cc, time.Second)
+	c.get(a.URL, false)
+	c.get(b.URL, false)
+	path, err := c.path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("%v is saved before flush", relayInfoFile)
+	}
+
+	c.flush()
+	c = newRelayInfoCache(cc, time.Second)
+	c.get(a.URL, false)
+	c.get(b.URL, false)
+	if a.InfoHits.Load() != 1 || b.InfoHits.Load() != 1 {
+		t.Errorf("NIP-11 documents fetched %d and %d times", a.InfoHits.Load(), b.InfoHits.Load())
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"github.com/nbd-wtf/go-nostr"
@@ -103,20 +102,11 @@ func removeEvent(args []string, cc confClass) error {
 	// calling Sign sets the event ID field and the event Sig field
 	ev.Sign(sk)
 
-	// publish the event to two relays
-	ctx := context.Background()
-	for _, url := range rl {
-		relay, err := nostr.RelayConnect(ctx, url)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		err = relay.Publish(ctx, ev)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		fmt.Printf("published to %s\n", url)
+	// publish the event to the write relays; failed relays are kept in the outbox
+	p := newPublisher(cc)
+	defer p.close()
+	if _, err := deliverEvent(p, ev, rl, cc); err != nil {
+		return err
 	}
 
 	return nil
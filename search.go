@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	//"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	sinceOption = "--since"
+	localFlag   = "--local"
+	nipSearch   = 50
+)
+
+/*
+	search {{{
+		[infomation for develop]
+		usage:
+			nostk search <query...> [--author npub] [--kind 1,30023] [--since 24h] [--local]
+		Sends a NIP-50 search filter to the search relays (see searchRelays).
+		When they find nothing, for example offline, or with --local,
+		the local event cache is searched instead.
+*/
+func search(args []string, cc confClass) error {
+	args, author, hasAuthor, err := extractOption(args, authorOption)
+	if err != nil {
+		return err
+	}
+	args, strKinds, err := extractOptions(args, kindOption)
+	if err != nil {
+		return err
+	}
+	args, since, hasSince, err := extractOption(args, sinceOption)
+	if err != nil {
+		return err
+	}
+	args, local := extractFlag(args, localFlag)
+	if len(args) < 3 {
+		return errors.New("Not set search words")
+	}
+	query := strings.TrimSpace(strings.Join(args[2:], " "))
+	if query == "" {
+		return errors.New("Not set search words")
+	}
+
+	c := cc.getConf()
+	filter := nostr.Filter{
+		Search: query,
+		Kinds:  []int{nostr.KindTextNote},
+		Limit:  c.Settings.DefaultReadNo,
+	}
+	if 0 < len(strKinds) {
+		if filter.Kinds, err = parseKinds(strKinds); err != nil {
+			return err
+		}
+	}
+	if hasAuthor {
+		pk, err := toHexId(author, "npub", "nprofile")
+		if err != nil {
+			return err
+		}
+		filter.Authors = []string{pk}
+	}
+	if hasSince {
+		ts, err := parsePastTime(since)
+		if err != nil {
+			return err
+		}
+		filter.Since = &ts
+	}
+
+	mf := loadMuteFilter(cc)
+
+	notes := []Recieve{}
+	if !local {
+		rs, err := cc.searchRelays()
+		if err != nil {
+			return err
+		}
+		if len(rs) < 1 {
+			fmt.Fprintln(os.Stderr, "No search relays. Add one with addSearchRelay.")
+		}
+		words := strings.Fields(strings.ToLower(query))
+		for _, ev := range fetchRelayEventsFrom(cc, rs, nostr.Filters{filter}, filter.Limit) {
+			// a relay may ignore "search" and send its latest notes, so the
+			// words and the rest of the filter are checked here
+			if mf.isMuted(ev.Event) || !filter.Matches(ev.Event) || !containsWords(ev.Content, words) {
+				continue
+			}
+			notes = append(notes, convertRelayEventToRecieve(&ev))
+		}
+		notes = uniqueNotes(notes)
+		cc.cacheNotes(notes)
+	}
+	if len(notes) < 1 {
+		if !local {
+			fmt.Fprintln(os.Stderr, "Nothing found on the search relays. Searching the local cache.")
+		}
+		evs, err := cc.searchEventCache(query, filter)
+		if err != nil {
+			return err
+		}
+		for _, ev := range evs {
+			if mf.isMuted(&ev) || isExpired(ev.Tags, nostr.Now()) {
+				continue
+			}
+			notes = append(notes, Recieve{Event: ev})
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Event.CreatedAt > notes[j].Event.CreatedAt
+	})
+	if 0 < filter.Limit && filter.Limit < len(notes) {
+		notes = notes[:filter.Limit]
+	}
+	reb := replaceEnginForBech32{}
+	for i := range notes {
+		if tmp, err := reb.replaceToBech32(notes[i]); err != nil {
+			return err
+		} else {
+			notes[i] = tmp
+		}
+	}
+	if data, err := json5.Marshal(notes); err != nil {
+		return err
+	} else {
+		fmt.Printf("%v", string(data))
+	}
+	return nil
+}
+
+// uniqueNotes drops the copies of a note received from several relays.
+func uniqueNotes(notes []Recieve) []Recieve {
+	seen := make(map[string]struct{})
+	ret := []Recieve{}
+	for _, n := range notes {
+		if _, ok := seen[n.Event.ID]; ok {
+			continue
+		}
+		seen[n.Event.ID] = struct{}{}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// }}}
+
+/* searchRelays {{{
+
+WHAT'S THIS?
+Returns the relays to send search filters to: the relays of our
+search relay list (kind 10007) and the read relays whose NIP-11
+document lists NIP-50.
+*/
+func (cc confClass) searchRelays() ([]string, error) {
+	var rs []string
+	if err := cc.getRelayList(&rs, readFlag); err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return nil, err
+	}
+	// nothing is published here, so no answer is no list
+	tgs, err := loadSearchRelayList(pk, cc, true)
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, listedSearchRelays(tgs)...)
+
+	c := newRelayInfoCache(cc, parseDurationOr(cc.ConfData.Settings.ConnectTimeout, defaultConnectTimeout))
+	supports := make([]bool, len(rs))
+	var wg sync.WaitGroup
+	for i, url := range rs {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			supports[i] = supportsNip(c.get(url, false).Info, nipSearch)
+		}(i, url)
+	}
+	wg.Wait()
+	c.flush()
+	for i, url := range rs {
+		if supports[i] {
+			ret = append(ret, url)
+		}
+	}
+	return uniqueRelays(ret), nil
+}
+
+/*
+supportsNip reports whether info lists nip in supported_nips.
+The numbers are float64 when read from JSON.
+*/
+func supportsNip(info *nip11.RelayInformationDocument, nip int) bool {
+	if info == nil {
+		return false
+	}
+	for _, n := range info.SupportedNIPs {
+		switch v := n.(type) {
+		case float64:
+			if int(v) == nip {
+				return true
+			}
+		case int:
+			if v == nip {
+				return true
+			}
+		case string:
+			if v == fmt.Sprint(nip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// }}}
+
+/* loadSearchRelayList {{{
+
+WHAT'S THIS?
+Reads the newest kind 10007 event of pk from the read relays and
+returns all its tags in list order; the relays are the "relay" tags.
+An empty list is returned when nothing has been published yet; see
+fetchReplaceableList for force.
+*/
+func loadSearchRelayList(pk string, cc confClass, force bool) (nostr.Tags, error) {
+	tgs := nostr.Tags{}
+	ev, err := fetchReplaceableList(cc, nostr.KindSearchRelayList, pk, force)
+	if err != nil || ev == nil {
+		return tgs, err
+	}
+	for _, tg := range ev.Tags {
+		if 0 < len(tg) {
+			tgs = append(tgs, tg)
+		}
+	}
+	return tgs, nil
+}
+
+// listedSearchRelays returns the relay urls of the "relay" tags of tgs.
+func listedSearchRelays(tgs nostr.Tags) []string {
+	rs := []string{}
+	for _, tg := range tgs {
+		if 1 < len(tg) && tg[indexTagName] == "relay" {
+			rs = append(rs, tg[1])
+		}
+	}
+	return rs
+}
+
+// }}}
+
+/* publishSearchRelayList {{{
+ */
+func publishSearchRelayList(tgs nostr.Tags, cc confClass) error {
+	dataRawArg := RawArg{
+		Kind:    nostr.KindSearchRelayList,
+		Content: "",
+		Tags:    tgs,
+	}
+	return publishRawArg(dataRawArg, keptTags, cc)
+}
+
+// }}}
+
+/*
+	addSearchRelay {{{
+		[infomation for develop]
+		usage:
+			nostk addSearchRelay <url> [--force]
+		kind: 10007
+		content: ""
+		tags [
+			"relay": relay url
+		]
+*/
+func addSearchRelay(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	url := nostr.NormalizeURL(args[2])
+	if !strings.HasPrefix(url, "ws") {
+		return fmt.Errorf("Invalid relay url %v", args[2])
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadSearchRelayList(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	tg := nostr.Tag{"relay", url}
+	if 0 <= indexOfTag(tgs, tg) {
+		return fmt.Errorf("%v is already a search relay", url)
+	}
+	tgs = append(tgs, tg)
+	return publishSearchRelayList(tgs, cc)
+}
+
+// }}}
+
+/*
+	removeSearchRelay {{{
+		[infomation for develop]
+		usage:
+			nostk removeSearchRelay <url> [--force]
+*/
+func removeSearchRelay(args []string, cc confClass) error {
+	args, force := extractFlag(args, forceFlag)
+	if len(args) != 3 {
+		return errors.New("Wrong number of parameters")
+	}
+	url := nostr.NormalizeURL(args[2])
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+
+	tgs, err := loadSearchRelayList(pk, cc, force)
+	if err != nil {
+		return err
+	}
+	tgs, removed := removeTag(tgs, nostr.Tag{"relay", url})
+	if removed == false {
+		return fmt.Errorf("%v is not a search relay", url)
+	}
+	return publishSearchRelayList(tgs, cc)
+}
+
+// }}}
+
+/*
+	catSearchRelays {{{
+		[infomation for develop]
+		usage:
+			nostk catSearchRelays
+		Displays every relay that search uses, with where it comes from.
+*/
+func catSearchRelays(args []string, cc confClass) error {
+	if len(args) != 2 {
+		return errors.New("Wrong number of parameters")
+	}
+	pk, err := cc.getMySelfHexPubkey()
+	if err != nil {
+		return err
+	}
+	tgs, err := loadSearchRelayList(pk, cc, true)
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]struct{})
+	for _, url := range listedSearchRelays(tgs) {
+		listed[nostr.NormalizeURL(url)] = struct{}{}
+	}
+	rs, err := cc.searchRelays()
+	if err != nil {
+		return err
+	}
+	for _, url := range rs {
+		if _, ok := listed[url]; ok {
+			fmt.Printf("%v\tkind 10007\n", url)
+		} else {
+			fmt.Printf("%v\tNIP-50\n", url)
+		}
+	}
+	return nil
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+func TestSupportsNip(t *testing.T) {
+	var info nip11.RelayInformationDocument
+	json.Unmarshal([]byte(`{"supported_nips":[1,11,"42",50]}`), &info)
+	for nip, want := range map[int]bool{50: true, 42: true, 11: true, 13: false} {
+		if got := supportsNip(&info, nip); got != want {
+			t.Errorf("supportsNip(%d) = %v", nip, got)
+		}
+	}
+	if supportsNip(nil, 50) {
+		t.Error("nil document supports NIP-50")
+	}
+}
+
+func TestSearchRelays(t *testing.T) {
+	withSearch := newTestRelay(t, &testRelay{NIPs: []int{1, 11, 50}}).URL
+	withoutSearch := newTestRelay(t, &testRelay{NIPs: []int{1, 11}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{
+		withSearch:    {Read: true},
+		withoutSearch: {Read: true},
+	})
+
+	rs, err := cc.searchRelays()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0] != nostr.NormalizeURL(withSearch) {
+		t.Errorf("searchRelays = %v, want only %v", rs, withSearch)
+	}
+}
+
+func TestSearchRelaysFromList(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	list := nostr.Event{
+		Kind:      nostr.KindSearchRelayList,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"relay", "wss://search.example.com"}},
+	}
+	list.Sign(sk)
+	home := newTestRelay(t, &testRelay{NIPs: []int{1}, Stored: []nostr.Event{list}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{home: {Read: true}})
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, ".hsec"), []byte(sk), 0600)
+
+	rs, err := cc.searchRelays()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0] != "wss://search.example.com" {
+		t.Errorf("searchRelays = %v, want the relay of kind 10007", rs)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	note := signedEvent(t, "hello nostr search")
+	r := newTestRelay(t, &testRelay{NIPs: []int{1, 11, 50}, Stored: []nostr.Event{note}})
+	cc := setupTestEnv(t, map[string]RwFlag{r.URL: {Read: true}})
+	cc.ConfData.Settings.DefaultReadNo = 10
+
+	if err := search([]string{"nostk", "search", "hello", "nostr"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if r.Searches.Load() != 1 {
+		t.Errorf("%d search filters sent, want 1", r.Searches.Load())
+	}
+	evs, err := cc.loadEventCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != note.ID {
+		t.Errorf("found notes are not cached: %v", evs)
+	}
+
+	// --local does not ask the relays
+	if err := search([]string{"nostk", "search", "hello", "--local"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	if r.Searches.Load() != 1 {
+		t.Errorf("--local sent a search filter")
+	}
+	if err := search([]string{"nostk", "search", "--local"}, cc); err == nil {
+		t.Error("search without words succeeded")
+	}
+}
+
+func TestSearchIgnoredBySomeRelay(t *testing.T) {
+	note := signedEvent(t, "hello nostr search")
+	other := signedEvent(t, "latest note")
+	// the test relay ignores "search" and sends every note
+	r := newTestRelay(t, &testRelay{NIPs: []int{1, 11, 50}, Stored: []nostr.Event{note, other}})
+	cc := setupTestEnv(t, map[string]RwFlag{r.URL: {Read: true}})
+	cc.ConfData.Settings.DefaultReadNo = 10
+
+	if err := search([]string{"nostk", "search", "hello"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	evs, err := cc.loadEventCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != note.ID {
+		t.Errorf("notes without the words are found: %v", evs)
+	}
+}
+
+func TestAddSearchRelayKeepsTags(t *testing.T) {
+	silent := newTestRelay(t, &testRelay{NoEOSE: true})
+	cc := setupTestEnv(t, map[string]RwFlag{silent.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.ConnectTimeout = "200ms"
+	if err := addSearchRelay([]string{"nostk", "addSearchRelay", "wss://b.example.com"}, cc); err == nil {
+		t.Error("added without reading the list")
+	}
+
+	sk, _ := cc.load(cc.ConfData.Filename.Hsec)
+	pk, _ := nostr.GetPublicKey(sk)
+	list := nostr.Event{
+		Kind:      nostr.KindSearchRelayList,
+		CreatedAt: nostr.Now() - 10,
+		Tags:      nostr.Tags{{"relay", "wss://a.example.com"}, {"alt", "search relays"}},
+	}
+	list.Sign(sk)
+	r := newTestRelay(t, &testRelay{Stored: []nostr.Event{list}})
+	cc = setupTestEnv(t, map[string]RwFlag{r.URL: {Read: true, Write: true}})
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 0.001
+	cc.ConfData.Settings.MinSuccess = 1
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), secretDir, ".hsec"), []byte(sk), 0600)
+
+	if err := addSearchRelay([]string{"nostk", "addSearchRelay", "wss://b.example.com"}, cc); err != nil {
+		t.Fatal(err)
+	}
+	tgs, err := loadSearchRelayList(pk, cc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 3 || tgs[1][indexTagName] != "alt" {
+		t.Errorf("search relay list = %v", tgs)
+	}
+	if rs := listedSearchRelays(tgs); len(rs) != 2 || rs[1] != "wss://b.example.com" {
+		t.Errorf("listed search relays = %v", rs)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/coder/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+/* testRelay {{{
+
+WHAT'S THIS?
+The relay the tests talk to over websocket. Set the fields, then start
+it with newTestRelay.
+
+REQ is answered with the matching events of Stored and of the accepted
+EVENTs, newest first up to the limit of the filter, then EOSE.
+Events are sent as they are, valid signature or not.
+A NIP-11 document is served when NIPs or Limits is set.
+*/
+type testRelay struct {
+	URL string
+
+	Stored []nostr.Event
+	Auth   bool // sends a challenge and answers auth-required until authenticated
+	NIPs   []int
+	Limits *nip11.RelayLimitationDocument
+	// Reply gives the OK for an EVENT; nil accepts every event.
+	Reply  func(ev nostr.Event) (bool, string)
+	NoOK   bool // never answers EVENT
+	NoEOSE bool // never ends the stored events
+
+	InfoHits atomic.Int32 // NIP-11 requests
+	Received atomic.Int32 // EVENTs answered
+	Searches atomic.Int32 // REQs with "search"
+	Rejected atomic.Int32 // messages answered with auth-required
+
+	mu       sync.Mutex
+	accepted []string
+	filters  []nostr.Filter
+}
+
+const testChallenge = "challenge-1234"
+
+func newTestRelay(t *testing.T, r *testRelay) *testRelay {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") == "application/nostr+json" {
+			r.InfoHits.Add(1)
+			info := nip11.RelayInformationDocument{Name: "test", Limitation: r.Limits}
+			info.AddSupportedNIPs(r.NIPs)
+			json.NewEncoder(w).Encode(info)
+			return
+		}
+		c, err := websocket.Accept(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		r.serve(req, c)
+	}))
+	t.Cleanup(srv.Close)
+	r.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return r
+}
+
+func (r *testRelay) serve(req *http.Request, c *websocket.Conn) {
+	ctx := req.Context()
+	send := func(v ...interface{}) {
+		b, _ := json.Marshal(v)
+		c.Write(ctx, websocket.MessageText, b)
+	}
+	if r.Auth {
+		send("AUTH", testChallenge)
+	}
+	authed := !r.Auth
+	for {
+		_, msg, err := c.Read(ctx)
+		if err != nil {
+			return
+		}
+		var env []json.RawMessage
+		if err := json.Unmarshal(msg, &env); err != nil || len(env) < 2 {
+			continue
+		}
+		var typ string
+		json.Unmarshal(env[0], &typ)
+		switch typ {
+		case "AUTH":
+			var ev nostr.Event
+			json.Unmarshal(env[1], &ev)
+			ok, _ := ev.CheckSignature()
+			ok = ok && ev.Kind == nostr.KindClientAuthentication &&
+				ev.Tags.FindWithValue("challenge", testChallenge) != nil
+			authed = authed || ok
+			send("OK", ev.ID, ok, "")
+		case "EVENT":
+			var ev nostr.Event
+			if err := json.Unmarshal(env[1], &ev); err != nil {
+				continue
+			}
+			if !authed {
+				r.Rejected.Add(1)
+				send("OK", ev.ID, false, "auth-required: members only")
+				continue
+			}
+			if r.NoOK {
+				continue
+			}
+			ok, reason := true, ""
+			if r.Reply != nil {
+				ok, reason = r.Reply(ev)
+			}
+			r.Received.Add(1)
+			if ok {
+				r.mu.Lock()
+				r.Stored = append(r.Stored, ev)
+				r.accepted = append(r.accepted, ev.ID)
+				r.mu.Unlock()
+			}
+			send("OK", ev.ID, ok, reason)
+		case "REQ":
+			var id string
+			json.Unmarshal(env[1], &id)
+			if !authed {
+				r.Rejected.Add(1)
+				send("CLOSED", id, "auth-required: members only")
+				continue
+			}
+			for _, raw := range env[2:] {
+				var f nostr.Filter
+				json.Unmarshal(raw, &f)
+				r.mu.Lock()
+				r.filters = append(r.filters, f)
+				r.mu.Unlock()
+				if f.Search != "" {
+					r.Searches.Add(1)
+				}
+				for _, ev := range r.match(f) {
+					send("EVENT", id, ev)
+				}
+			}
+			if !r.NoEOSE {
+				send("EOSE", id)
+			}
+		}
+	}
+}
+
+// match returns the stored events matching f, newest first up to f.Limit
+// and max_limit of Limits.
+func (r *testRelay) match(f nostr.Filter) []nostr.Event {
+	r.mu.Lock()
+	evs := append([]nostr.Event{}, r.Stored...)
+	r.mu.Unlock()
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].CreatedAt > evs[j].CreatedAt
+	})
+	ret := []nostr.Event{}
+	for i := range evs {
+		if !f.Matches(&evs[i]) {
+			continue
+		}
+		ret = append(ret, evs[i])
+		if r.Limits != nil && 0 < r.Limits.MaxLimit && r.Limits.MaxLimit <= len(ret) {
+			break
+		}
+		if 0 < f.Limit && f.Limit <= len(ret) {
+			break
+		}
+	}
+	return ret
+}
+
+// acceptedIDs returns the ids of the accepted EVENTs in arrival order.
+func (r *testRelay) acceptedIDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.accepted...)
+}
+
+// requested counts the filters received for kind.
+func (r *testRelay) requested(kind int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, f := range r.filters {
+		if slices.Contains(f.Kinds, kind) {
+			n++
+		}
+	}
+	return n
+}
+
+// }}}
+
+// setupTestEnv writes relays.json, the key and an empty custom emoji list
+// to a temporary home and returns the configuration reading them.
+func setupTestEnv(t *testing.T, relays map[string]RwFlag) confClass {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cc := confClass{}
+	cc.ConfData.Filename.Relays = "relays.json"
+	cc.ConfData.Filename.Hsec = ".hsec"
+	cc.ConfData.Filename.Emoji = "customemoji.json"
+	cc.ConfData.Filename.EmojiCache = "emojicache.json"
+	cc.ConfData.Settings.ConnectTimeout = "2s"
+	cc.ConfData.Settings.OkTimeout = "2s"
+	cc.ConfData.Settings.MultiplierReadRelayWaitTime = 3
+	b, _ := json.Marshal(relays)
+	d := filepath.Join(home, secretDir)
+	os.MkdirAll(d, 0700)
+	os.WriteFile(filepath.Join(d, "relays.json"), b, 0600)
+	os.WriteFile(filepath.Join(d, ".hsec"), []byte(nostr.GeneratePrivateKey()), 0600)
+	os.WriteFile(filepath.Join(d, "customemoji.json"), []byte("{}"), 0600)
+	return cc
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip40"
+	//"log"
+	"time"
+)
+
+const (
+	linkOption   = "--link"
+	expireOption = "--expire"
+	noStatusFlag = "--no-status"
+)
+
+/* status types {{{
+ */
+type StatusTypeTbl map[string]struct{}
+
+func NewStatusTypeTbl() StatusTypeTbl {
+	return StatusTypeTbl{
+		"general": {},
+		"music":   {},
+	}
+}
+func (r StatusTypeTbl) has(s string) bool {
+	_, exists := r[s]
+	return exists
+}
+func (r StatusTypeTbl) keys() []string {
+	ret := []string{}
+	for k := range r {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+// }}}
+
+/* publishStatus {{{
+ */
+func publishStatus(statusType string, content string, tgs nostr.Tags, cc confClass) error {
+	dataRawArg := RawArg{
+		Kind:    nostr.KindUserStatuses,
+		Content: content,
+		Tags:    append(nostr.Tags{{"d", statusType}}, tgs...),
+	}
+	return publishRawArg(dataRawArg, checkedTags, cc)
+}
+
+// }}}
+
+/*
+	setStatus {{{
+		[infomation for develop]
+		usage:
+			nostk setStatus [general|music] <text> [--link url] [--expire 1h]
+		kind: 30315
+		--expire is taken by setExpireOption in main.
+		content: status text
+		tags [
+			"d": general or music
+			"r": url (optional)
+			"expiration": unix time (optional)
+		]
+*/
+func setStatus(args []string, cc confClass) error {
+	args, link, hasLink, err := extractOption(args, linkOption)
+	if err != nil {
+		return err
+	}
+	statusType := "general"
+	var content string
+	switch len(args) {
+	case 3:
+		content = args[2]
+	case 4:
+		statusType = args[2]
+		content = args[3]
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+	if NewStatusTypeTbl().has(statusType) == false {
+		return fmt.Errorf("Not supported status type %v", statusType)
+	}
+	if len(content) < 1 {
+		return errors.New("Not set status text. Use \"nostk clearStatus\" to clear status.")
+	}
+
+	tgs := nostr.Tags{}
+	if hasLink {
+		tgs = append(tgs, nostr.Tag{"r", link})
+	}
+	return publishStatus(statusType, content, tgs, cc)
+}
+
+// }}}
+
+/*
+	clearStatus {{{
+		[infomation for develop]
+		usage:
+			nostk clearStatus [general|music]
+		NIP-38: a status with empty content is cleared.
+*/
+func clearStatus(args []string, cc confClass) error {
+	statusType := "general"
+	switch len(args) {
+	case 2:
+	case 3:
+		statusType = args[2]
+	default:
+		return errors.New("Wrong number of parameters")
+	}
+	if NewStatusTypeTbl().has(statusType) == false {
+		return fmt.Errorf("Not supported status type %v", statusType)
+	}
+	return publishStatus(statusType, "", nostr.Tags{}, cc)
+}
+
+// }}}
+
+/* getStatusType {{{
+ */
+func getStatusType(ev *nostr.Event) string {
+	for _, tg := range ev.Tags {
+		if 1 < len(tg) && tg[indexTagName] == "d" {
+			return tg[1]
+		}
+	}
+	return ""
+}
+
+// }}}
+
+/* latestStatuses {{{
+
+WHAT'S THIS?
+Returns the current status of each author as map[pubkey]map[type]text.
+Only the newest event of each author and type counts, and cleared or
+expired statuses are left out.
+*/
+func latestStatuses(evs []*nostr.Event, now nostr.Timestamp) map[string]map[string]string {
+	latest := make(map[string]*nostr.Event)
+	for _, ev := range evs {
+		if ev.Kind != nostr.KindUserStatuses {
+			continue
+		}
+		key := ev.PubKey + ":" + getStatusType(ev)
+		if v, ok := latest[key]; !ok || v.CreatedAt < ev.CreatedAt {
+			latest[key] = ev
+		}
+	}
+
+	ret := make(map[string]map[string]string)
+	for _, ev := range latest {
+		if len(ev.Content) < 1 {
+			continue
+		}
+		if exp := nip40.GetExpiration(ev.Tags); exp != -1 && exp <= now {
+			continue
+		}
+		if _, ok := ret[ev.PubKey]; !ok {
+			ret[ev.PubKey] = make(map[string]string)
+		}
+		ret[ev.PubKey][getStatusType(ev)] = ev.Content
+	}
+	return ret
+}
+
+// }}}
+
+/* fetchStatuses {{{
+ */
+func fetchStatuses(ctx context.Context, cc confClass, pool *nostr.SimplePool, rs []string, authors []string, wt time.Duration) map[string]map[string]string {
+	if len(authors) < 1 {
+		return map[string]map[string]string{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, wt)
+	defer cancel()
+
+	filters := nostr.Filters{{
+		Kinds:   []int{nostr.KindUserStatuses},
+		Authors: authors,
+		Tags:    nostr.TagMap{"d": NewStatusTypeTbl().keys()},
+	}}
+	evs := []*nostr.Event{}
+	for ev := range cc.subManyEose(ctx, pool, rs, filters) {
+		evs = append(evs, ev.Event)
+	}
+	return latestStatuses(evs, nostr.Now())
+}
+
+// }}}
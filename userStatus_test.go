@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"testing"
+)
+
+func TestLatestStatuses(t *testing.T) {
+	const (
+		pkA = "c08805f9bd4849049325747a8086a3c0e069adeb19d8f59ec4e6b5157b7d3416"
+		pkB = "4cf6b2efbf6ff7a7e3f3a5db1d7c7b3b7a05ef1bca9d1bbd0f8fa5c0da3fa4ec"
+	)
+	evs := []*nostr.Event{
+		{PubKey: pkA, Kind: 30315, CreatedAt: 10, Content: "old", Tags: nostr.Tags{{"d", "general"}}},
+		{PubKey: pkA, Kind: 30315, CreatedAt: 20, Content: "working", Tags: nostr.Tags{{"d", "general"}}},
+		{PubKey: pkA, Kind: 30315, CreatedAt: 20, Content: "expired song", Tags: nostr.Tags{{"d", "music"}, {"expiration", "50"}}},
+		{PubKey: pkB, Kind: 30315, CreatedAt: 10, Content: "hello", Tags: nostr.Tags{{"d", "general"}}},
+		{PubKey: pkB, Kind: 30315, CreatedAt: 30, Content: "", Tags: nostr.Tags{{"d", "general"}}},
+	}
+	ret := latestStatuses(evs, 100)
+	if ret[pkA]["general"] != "working" {
+		t.Fatalf("got status: %v, Want status: working", ret[pkA]["general"])
+	}
+	if _, ok := ret[pkA]["music"]; ok {
+		t.Fatalf("expired status is displayed: %v", ret[pkA])
+	}
+	if _, ok := ret[pkB]; ok {
+		t.Fatalf("cleared status is displayed: %v", ret[pkB])
+	}
+}
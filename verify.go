@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nbd-wtf/go-nostr"
+	"io"
+	//"log"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+const (
+	showRejectedFlag = "--show-rejected"
+)
+
+/* verifyLocally {{{
+
+WHAT'S THIS?
+A relay option that turns off the signature check of go-nostr.
+go-nostr drops bad signatures without telling anyone and does not
+check the id, so nostk checks both itself and counts what it drops.
+*/
+type verifyLocally struct{}
+
+func (verifyLocally) ApplyRelayOption(r *nostr.Relay) {
+	r.AssumeValid = true
+}
+
+// }}}
+
+/*
+EventVerifier {{{
+
+WHAT'S THIS?
+Checks the id and the signature of received events and counts
+the events of each relay for the fetch report.
+*/
+type VerifyCount struct {
+	Received int `json:"received"`
+	Rejected int `json:"rejected"`
+}
+type EventVerifier struct {
+	showRejected bool
+
+	mu     sync.Mutex
+	counts map[string]*VerifyCount
+}
+
+func newEventVerifier(showRejected bool) *EventVerifier {
+	return &EventVerifier{showRejected: showRejected, counts: make(map[string]*VerifyCount)}
+}
+
+// setVerifyFlags takes --show-rejected out of the arguments of any subcommand.
+func (cc *confClass) setVerifyFlags(args []string) []string {
+	args, show := extractFlag(args, showRejectedFlag)
+	cc.verifier = newEventVerifier(show)
+	return args
+}
+
+// }}}
+
+/* EventVerifier.verify {{{
+
+WHAT'S THIS?
+Reports whether ev is genuine. A nil verifier checks without counting.
+With --show-rejected, rejected events are printed to w.
+*/
+func (v *EventVerifier) verify(ev nostr.RelayEvent, w io.Writer) bool {
+	err := checkSignedEvent(*ev.Event)
+	if v == nil {
+		return err == nil
+	}
+	url := ""
+	if ev.Relay != nil {
+		url = ev.Relay.URL
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.counts[url]
+	if !ok {
+		c = &VerifyCount{}
+		v.counts[url] = c
+	}
+	c.Received++
+	if err == nil {
+		return true
+	}
+	c.Rejected++
+	if v.showRejected {
+		b, _ := json.Marshal(ev.Event)
+		fmt.Fprintf(w, "rejected from %v: %v\n%v\n", url, err, string(b))
+	}
+	return false
+}
+
+// }}}
+
+/* EventVerifier.report {{{
+
+WHAT'S THIS?
+Prints the number of received and rejected events of each relay.
+Printed only when an event was rejected or --show-rejected is given,
+so that the JSON on stdout is not disturbed.
+*/
+func (v *EventVerifier) report(w io.Writer) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	rejected := 0
+	urls := []string{}
+	for url, c := range v.counts {
+		rejected += c.Rejected
+		urls = append(urls, url)
+	}
+	if len(urls) < 1 || (rejected < 1 && !v.showRejected) {
+		return
+	}
+	sort.Strings(urls)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RELAY\tRECEIVED\tREJECTED")
+	for _, url := range urls {
+		fmt.Fprintf(tw, "%v\t%d\t%d\n", url, v.counts[url].Received, v.counts[url].Rejected)
+	}
+	tw.Flush()
+}
+
+// }}}
+
+/* subManyEose {{{
+
+WHAT'S THIS?
+pool.SubManyEose that passes only events with a valid id and signature.
+Every read from relays goes through here.
+*/
+func (cc confClass) subManyEose(ctx context.Context, pool *nostr.SimplePool, rs []string, filters nostr.Filters) chan nostr.RelayEvent {
+	ch := make(chan nostr.RelayEvent)
+	go func() {
+		defer close(ch)
+		for ev := range pool.SubManyEose(ctx, rs, filters) {
+			if !cc.verifier.verify(ev, os.Stderr) {
+				continue
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// }}}
+
+// vim: set ts=2 sw=2 et:
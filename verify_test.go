@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"github.com/nbd-wtf/go-nostr"
+	"strings"
+	"testing"
+)
+
+// forgedEvents returns a genuine event, one whose content was changed
+// after signing and one with the signature of another event.
+func forgedEvents(t *testing.T) (valid, tampered, badSig nostr.Event) {
+	valid = signedEvent(t, "genuine")
+	tampered = signedEvent(t, "original")
+	tampered.Content = "forged"
+	badSig = signedEvent(t, "stolen signature")
+	badSig.Sig = valid.Sig
+	return
+}
+
+func TestEventVerifier(t *testing.T) {
+	valid, tampered, badSig := forgedEvents(t)
+	relay := &nostr.Relay{URL: "wss://relay.example.com"}
+	var out bytes.Buffer
+
+	v := newEventVerifier(true)
+	for _, ev := range []nostr.Event{valid, tampered, badSig} {
+		ok := v.verify(nostr.RelayEvent{Event: &ev, Relay: relay}, &out)
+		if ok != (ev.ID == valid.ID) {
+			t.Errorf("verify(%v) = %v", ev.Content, ok)
+		}
+	}
+	if c := v.counts[relay.URL]; c.Received != 3 || c.Rejected != 2 {
+		t.Errorf("counts = %+v", c)
+	}
+	if !strings.Contains(out.String(), tampered.ID) || !strings.Contains(out.String(), badSig.ID) {
+		t.Errorf("rejected events are not shown:\n%v", out.String())
+	}
+
+	out.Reset()
+	v.report(&out)
+	if !strings.Contains(out.String(), relay.URL) {
+		t.Errorf("report = %q", out.String())
+	}
+
+	// without rejections or --show-rejected, nothing is reported
+	v = newEventVerifier(false)
+	v.verify(nostr.RelayEvent{Event: &valid, Relay: relay}, &out)
+	out.Reset()
+	v.report(&out)
+	if out.Len() != 0 {
+		t.Errorf("report = %q", out.String())
+	}
+
+	var nilVerifier *EventVerifier
+	if nilVerifier.verify(nostr.RelayEvent{Event: &tampered}, &out) {
+		t.Error("nil verifier accepted a tampered event")
+	}
+}
+
+func TestFetchDropsForgedEvents(t *testing.T) {
+	valid, tampered, badSig := forgedEvents(t)
+	url := newTestRelay(t, &testRelay{Stored: []nostr.Event{tampered, valid, badSig}}).URL
+	cc := setupTestEnv(t, map[string]RwFlag{url: {Read: true}})
+	cc.verifier = newEventVerifier(false)
+
+	evs, err := fetchEvents(cc, nostr.Filters{{Kinds: []int{1}}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != valid.ID {
+		t.Errorf("fetchEvents = %v", evs)
+	}
+	c := cc.verifier.counts[nostr.NormalizeURL(url)]
+	if c == nil || c.Received != 3 || c.Rejected != 2 {
+		t.Errorf("counts = %+v", cc.verifier.counts)
+	}
+}